@@ -2,9 +2,10 @@ module l2
 
 go 1.23.3
 
-require github.com/beevik/ntp v1.4.3
-
 require (
-	golang.org/x/net v0.25.0 // indirect
-	golang.org/x/sys v0.20.0 // indirect
+	github.com/beevik/ntp v1.4.3
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/net v0.25.0
+	golang.org/x/sys v0.20.0
+	golang.org/x/text v0.15.0
 )
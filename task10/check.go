@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// disorderError описывает первую строку, нарушающую порядок при -c.
+type disorderError struct {
+	name string // имя входа, "-" для STDIN
+	line int    // номер строки с 1
+	text string
+}
+
+func (e *disorderError) Error() string {
+	return fmt.Sprintf("%s:%d: disorder: %s", e.name, e.line, e.text)
+}
+
+// checkStream проверяет, что строки r упорядочены по opts, держа в памяти
+// только предыдущую строку. Первую строку не на своём месте возвращает как
+// *disorderError; при -u нарушением считается и повтор.
+func checkStream(r io.Reader, name string, opts SortOptions) error {
+	opts.resolveKeys()
+	in := bufio.NewReader(r)
+	var prev Line
+	for n := 1; ; n++ {
+		text, err := readLine(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line := makeLine(text, opts)
+		if n > 1 {
+			c := compareLines(prev, line, opts)
+			if c > 0 || opts.unique && c == 0 {
+				return &disorderError{name: name, line: n, text: text}
+			}
+		}
+		prev = line
+	}
+}
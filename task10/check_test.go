@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestCheckStream тестирует проверку порядка (-c)
+func TestCheckStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     SortOptions
+		expected string // текст ошибки, "" — данные отсортированы
+	}{
+		{
+			name:  "отсортировано",
+			input: "a\nb\nb\nc\n",
+		},
+		{
+			name:  "пустой вход",
+			input: "",
+		},
+		{
+			name:     "первое нарушение",
+			input:    "a\nc\nb\na\n",
+			expected: "in.txt:3: disorder: b",
+		},
+		{
+			name:     "повтор при -u",
+			input:    "a\nb\nb\n",
+			opts:     SortOptions{unique: true},
+			expected: "in.txt:3: disorder: b",
+		},
+		{
+			name:  "числовой ключ по убыванию",
+			input: "x\t10\ny\t9\nz\t1",
+			opts:  SortOptions{keys: []keySpec{{startField: 2, endField: 2, numeric: true, reverse: true}}},
+		},
+		{
+			name:     "числовой ключ не по порядку",
+			input:    "x\t2\ny\t10\nz\t9\n",
+			opts:     SortOptions{numeric: true, keys: []keySpec{{startField: 2, endField: 2}}},
+			expected: "in.txt:3: disorder: z\t9",
+		},
+		{
+			name:  "без учёта регистра",
+			input: "apple\nBanana\ncherry\n",
+			opts:  SortOptions{foldCase: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkStream(strings.NewReader(test.input), "in.txt", test.opts)
+			if test.expected == "" {
+				if err != nil {
+					t.Errorf("ожидалось nil, получено: %v", err)
+				}
+				return
+			}
+			var disorder *disorderError
+			if !errors.As(err, &disorder) {
+				t.Fatalf("ожидалась ошибка порядка, получено: %v", err)
+			}
+			if disorder.Error() != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, disorder.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// newCollator создаёт правила сравнения строк для локали. Для "", "C"
+// и "POSIX" возвращает nil — строки сравниваются побайтно.
+func newCollator(locale string) (*collate.Collator, error) {
+	tag, ok, err := parseLocale(locale)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return collate.New(tag), nil
+}
+
+// parseLocale разбирает локаль вида "ru", "en-US" или "ru_RU.UTF-8";
+// ok == false для "", "C" и "POSIX".
+func parseLocale(locale string) (tag language.Tag, ok bool, err error) {
+	locale, _, _ = strings.Cut(locale, ".")
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return language.Und, false, nil
+	}
+	tag, err = language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	return tag, err == nil, err
+}
+
+// collationKey возвращает ключ, побайтное сравнение которого
+// соответствует правилам локали.
+func collationKey(c *collate.Collator, s string) string {
+	var buf collate.Buffer
+	return string(c.KeyFromString(&buf, s))
+}
+
+// dictionaryOrder оставляет в строке только пробелы, буквы и цифры (-d).
+func dictionaryOrder(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
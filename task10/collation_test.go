@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestSortCollation тестирует регистр, словарный порядок и правила локали
+func TestSortCollation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		opts     SortOptions
+		locale   string
+		expected []string
+	}{
+		{
+			name:     "побайтно",
+			input:    []string{"zoo", "Äpfel", "apple", "Zebra"},
+			expected: []string{"Zebra", "apple", "zoo", "Äpfel"},
+		},
+		{
+			name:     "без учёта регистра",
+			input:    []string{"b", "B", "a", "_", "A"},
+			opts:     SortOptions{foldCase: true},
+			expected: []string{"A", "a", "B", "b", "_"},
+		},
+		{
+			name:     "без учёта регистра, стабильно",
+			input:    []string{"b", "B", "a", "_", "A"},
+			opts:     SortOptions{foldCase: true, stable: true},
+			expected: []string{"a", "A", "b", "B", "_"},
+		},
+		{
+			name:     "последнее сравнение по правилам локали",
+			input:    []string{"Б 1", "а 1", "б 1"},
+			opts:     SortOptions{keys: []keySpec{{startField: 2, endField: 2}}},
+			locale:   "ru",
+			expected: []string{"а 1", "б 1", "Б 1"},
+		},
+		{
+			name:     "словарный порядок",
+			input:    []string{"c-3", "a_2", "(b)1"},
+			opts:     SortOptions{dictionary: true},
+			expected: []string{"a_2", "(b)1", "c-3"},
+		},
+		{
+			name:     "английская локаль",
+			input:    []string{"zoo", "Äpfel", "apple", "Zebra"},
+			locale:   "en",
+			expected: []string{"Äpfel", "apple", "Zebra", "zoo"},
+		},
+		{
+			name:     "русская локаль",
+			input:    []string{"ёж", "яблоко", "Елка", "ель", "Ангара"},
+			locale:   "ru_RU.UTF-8",
+			expected: []string{"Ангара", "ёж", "Елка", "ель", "яблоко"},
+		},
+		{
+			name:     "локаль в ключе",
+			input:    []string{"3\tЯрослав", "1\tёлка", "2\tАнна"},
+			opts:     SortOptions{keys: []keySpec{{startField: 2, endField: 2, ignoreBlanks: true}}},
+			locale:   "ru",
+			expected: []string{"2\tАнна", "1\tёлка", "3\tЯрослав"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			collator, err := newCollator(test.locale)
+			if err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			opts.collator = collator
+
+			var out bytes.Buffer
+			input := strings.Join(test.input, "\n")
+			if err := sortStream(strings.NewReader(input), &out, opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			expected := strings.Join(test.expected, "\n") + "\n"
+			if out.String() != expected {
+				t.Errorf("ожидалось %q, получено %q", expected, out.String())
+			}
+		})
+	}
+}
+
+// TestNewCollator тестирует разбор имени локали
+func TestNewCollator(t *testing.T) {
+	for _, locale := range []string{"", "C", "POSIX.UTF-8"} {
+		if c, err := newCollator(locale); c != nil || err != nil {
+			t.Errorf("%q: ожидалось побайтное сравнение, получено %v, %v", locale, c, err)
+		}
+	}
+	if _, err := newCollator("not a locale"); err == nil {
+		t.Errorf("ожидалась ошибка для неверной локали")
+	}
+}
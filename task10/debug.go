@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// writeDebug выводит строку и подчёркивает под ней каждый ключ, а если
+// строки сравниваются целиком — всю строку, как GNU sort --debug.
+// Табуляция показывается символом '>', чтобы подчёркивание совпало по позиции.
+// У числовых ключей и месяцев подчёркивается только разобранная часть.
+func writeDebug(w io.StringWriter, line string, opts SortOptions) error {
+	var b strings.Builder
+	b.WriteString(strings.ReplaceAll(line, "\t", ">"))
+	b.WriteByte('\n')
+
+	bounds := splitFields(line, opts)
+	for i := 0; i < opts.keyCount(); i++ {
+		k := opts.keyAt(i)
+		start, end := keyRange(line, bounds, k)
+		from, to := keyMatch(line[start:end], k, opts.numbers)
+		start, end = start+from, start+to
+		b.WriteString(strings.Repeat(" ", utf8.RuneCountInString(line[:start])))
+		if start == end {
+			b.WriteString("^ no match for key\n")
+			continue
+		}
+		b.WriteString(strings.Repeat("_", utf8.RuneCountInString(line[start:end])))
+		b.WriteByte('\n')
+	}
+	if opts.lastResort() && len(opts.keys) > 0 {
+		b.WriteString(strings.Repeat("_", utf8.RuneCountInString(line)))
+		b.WriteByte('\n')
+	}
+
+	_, err := w.WriteString(b.String())
+	return err
+}
+
+// keyMatch возвращает границы части ключа, которая участвует в сравнении.
+func keyMatch(key string, k keySpec, f numberFormat) (start, end int) {
+	switch k.kind() {
+	case numericKey, humanKey:
+		_, start, end = parseNumeric(key, f, k.kind() == humanKey)
+	case generalKey:
+		start = len(key) - len(strings.TrimLeft(key, " \t"))
+		end = start + len(floatPrefix(key[start:], f.point()))
+	case monthKey:
+		_, start, end = parseMonth(key)
+	default:
+		end = len(key)
+	}
+	return start, end
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestWriteDebug тестирует подчёркивание ключей в режиме --debug
+func TestWriteDebug(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		opts     SortOptions
+		expected string
+	}{
+		{
+			name:     "вся строка",
+			line:     "привет",
+			opts:     SortOptions{},
+			expected: "привет\n______\n",
+		},
+		{
+			name:     "ключ и последнее сравнение",
+			line:     "ann\t300",
+			opts:     SortOptions{separator: '\t', keys: []keySpec{{startField: 2, endField: 2, numeric: true}}},
+			expected: "ann>300\n    ___\n_______\n",
+		},
+		{
+			name:     "несколько ключей без последнего сравнения",
+			line:     "x  ab.cd",
+			opts:     SortOptions{stable: true, keys: []keySpec{{startField: 2, startChar: 2, endField: 2, endChar: 3, ignoreBlanks: true}, {startField: 1, endField: 1}}},
+			expected: "x  ab.cd\n    __\n_\n",
+		},
+		{
+			name:     "нет поля",
+			line:     "abc",
+			opts:     SortOptions{stable: true, keys: []keySpec{{startField: 3}}},
+			expected: "abc\n   ^ no match for key\n",
+		},
+		{
+			name:     "нечисловой ключ",
+			line:     "N/A",
+			opts:     SortOptions{numeric: true},
+			expected: "N/A\n^ no match for key\n",
+		},
+		{
+			name:     "число с суффиксом",
+			line:     "a 12KiB",
+			opts:     SortOptions{stable: true, keys: []keySpec{{startField: 2, humanNumeric: true}}},
+			expected: "a 12KiB\n  ____\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeDebug(&b, test.line, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if b.String() != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultBufferSize — объём памяти под строки по умолчанию (-S)
+const defaultBufferSize = 256 << 20
+
+// mergeFanIn — сколько временных файлов сливается за один проход
+const mergeFanIn = 16
+
+// parseBufferSize разбирает размер буфера в формате GNU sort: число
+// с суффиксом b, K, M, G или T; без суффикса — килобайты.
+func parseBufferSize(s string) (int64, error) {
+	if s == "" {
+		return defaultBufferSize, nil
+	}
+	multiplier := int64(1 << 10)
+	switch s[len(s)-1] {
+	case 'b', 'B':
+		multiplier = 1
+	case 'k', 'K':
+		multiplier = 1 << 10
+	case 'm', 'M':
+		multiplier = 1 << 20
+	case 'g', 'G':
+		multiplier = 1 << 30
+	case 't', 'T':
+		multiplier = 1 << 40
+	}
+	if s[len(s)-1] < '0' || s[len(s)-1] > '9' {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid buffer size: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// readLine читает строку без завершающего перевода строки (и \r перед ним).
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// sortLines сортирует строки в памяти, при --parallel — в нескольких потоках.
+// Строки с равными ключами сохраняют исходный порядок, поэтому результат
+// совпадает с внешней сортировкой. По умолчанию --parallel равен 1:
+// параллельная сортировка включается только явно, пока BenchmarkSortParallel
+// на многоядерной машине не покажет ускорение.
+func sortLines(lines []Line, opts SortOptions) {
+	sortLinesParallel(lines, opts, opts.parallel)
+}
+
+// lineWriter выводит строки, пропуская повторы при -u.
+type lineWriter struct {
+	w    *bufio.Writer
+	opts SortOptions
+	last *Line
+}
+
+func (lw *lineWriter) write(line Line) error {
+	if lw.opts.unique && lw.last != nil && compareLines(*lw.last, line, lw.opts) == 0 {
+		return nil
+	}
+	lw.last = &line
+	if lw.opts.debug {
+		return writeDebug(lw.w, line.original, lw.opts)
+	}
+	if _, err := lw.w.WriteString(line.original); err != nil {
+		return err
+	}
+	return lw.w.WriteByte('\n')
+}
+
+// sortStream сортирует строки из r и пишет результат в w.
+func sortStream(r io.Reader, w io.Writer, opts SortOptions) error {
+	return sortReaders([]io.Reader{r}, w, opts)
+}
+
+// sortReaders сортирует строки всех входов вместе и пишет результат в w.
+// Пока строки помещаются в opts.bufferSize, сортировка идёт в памяти; иначе
+// отсортированные части сбрасываются во временные файлы в opts.tempDir
+// и сливаются кучей.
+func sortReaders(inputs []io.Reader, w io.Writer, opts SortOptions) error {
+	opts.resolveKeys()
+	s := &externalSorter{opts: opts}
+	defer s.cleanup()
+
+	for _, r := range inputs {
+		in := bufio.NewReader(r)
+		for {
+			text, err := readLine(in)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := s.add(makeLine(text, opts)); err != nil {
+				return err
+			}
+		}
+	}
+
+	out := bufio.NewWriter(w)
+	lw := &lineWriter{w: out, opts: opts}
+	if len(s.runs) == 0 {
+		sortLines(s.chunk, opts)
+		for _, line := range s.chunk {
+			if err := lw.write(line); err != nil {
+				return err
+			}
+		}
+		return out.Flush()
+	}
+
+	if len(s.chunk) > 0 {
+		if err := s.spill(s.chunk); err != nil {
+			return err
+		}
+	}
+	for len(s.runs) > mergeFanIn {
+		if err := s.mergePass(); err != nil {
+			return err
+		}
+	}
+	if err := s.merge(s.runs, lw.write); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// externalSorter хранит временные файлы с отсортированными частями входа
+// в порядке их появления.
+type externalSorter struct {
+	opts  SortOptions
+	runs  []string
+	chunk []Line // строки, ещё не сброшенные во временный файл
+	size  int64  // оценка памяти под chunk, см. Line.size
+}
+
+// add добавляет строку в текущую часть входа и сбрасывает часть во
+// временный файл, когда её оценка достигает opts.bufferSize.
+func (s *externalSorter) add(line Line) error {
+	s.chunk = append(s.chunk, line)
+	s.size += line.size()
+	if s.opts.bufferSize > 0 && s.size >= s.opts.bufferSize {
+		if err := s.spill(s.chunk); err != nil {
+			return err
+		}
+		s.chunk, s.size = s.chunk[:0], 0
+	}
+	return nil
+}
+
+// spill сортирует часть входа и записывает её во временный файл.
+func (s *externalSorter) spill(chunk []Line) error {
+	sortLines(chunk, s.opts)
+	return s.writeRun(func(write func(Line) error) error {
+		for _, line := range chunk {
+			if err := write(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeRun создаёт временный файл и заполняет его строками из fill.
+func (s *externalSorter) writeRun(fill func(write func(Line) error) error) error {
+	file, err := os.CreateTemp(s.opts.tempDir, "sort-*")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, file.Name())
+
+	w := bufio.NewWriter(file)
+	err = fill(func(line Line) error {
+		if _, err := w.WriteString(line.original); err != nil {
+			return err
+		}
+		return w.WriteByte('\n')
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// mergePass сливает временные файлы группами по mergeFanIn, сохраняя их порядок.
+func (s *externalSorter) mergePass() error {
+	runs := s.runs
+	s.runs = nil
+	for len(runs) > 0 {
+		n := min(mergeFanIn, len(runs))
+		group := runs[:n]
+		runs = runs[n:]
+		err := s.writeRun(func(write func(Line) error) error {
+			return s.merge(group, write)
+		})
+		for _, name := range group {
+			os.Remove(name)
+		}
+		if err != nil {
+			s.runs = append(s.runs, runs...)
+			return err
+		}
+	}
+	return nil
+}
+
+// merge сливает временные файлы runs.
+func (s *externalSorter) merge(runs []string, write func(Line) error) error {
+	inputs := make([]io.Reader, len(runs))
+	for i, name := range runs {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		inputs[i] = file
+	}
+	return mergeReaders(inputs, write, s.opts)
+}
+
+// mergeStreams сливает уже отсортированные входы в w за один проход (-m).
+func mergeStreams(inputs []io.Reader, w io.Writer, opts SortOptions) error {
+	opts.resolveKeys()
+	out := bufio.NewWriter(w)
+	lw := &lineWriter{w: out, opts: opts}
+	if err := mergeReaders(inputs, lw.write, opts); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// mergeReaders выполняет k-way слияние отсортированных входов. При равных
+// ключах первой идёт строка из более раннего входа, что сохраняет порядок
+// исходных строк.
+func mergeReaders(inputs []io.Reader, write func(Line) error, opts SortOptions) error {
+	h := &mergeHeap{opts: opts}
+	for i, r := range inputs {
+		src := &mergeSource{in: bufio.NewReader(r), index: i}
+		ok, err := src.next(opts)
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.items = append(h.items, src)
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		src := h.items[0]
+		if err := write(src.line); err != nil {
+			return err
+		}
+		ok, err := src.next(opts)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// cleanup удаляет оставшиеся временные файлы.
+func (s *externalSorter) cleanup() {
+	for _, name := range s.runs {
+		os.Remove(name)
+	}
+	s.runs = nil
+}
+
+// mergeSource — текущая строка одного входа слияния.
+type mergeSource struct {
+	in    *bufio.Reader
+	index int
+	line  Line
+}
+
+func (src *mergeSource) next(opts SortOptions) (bool, error) {
+	text, err := readLine(src.in)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	src.line = makeLine(text, opts)
+	return true, nil
+}
+
+// mergeHeap — минимальная куча источников слияния.
+type mergeHeap struct {
+	opts  SortOptions
+	items []*mergeSource
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := compareLines(h.items[i].line, h.items[j].line, h.opts); c != 0 {
+		return c < 0
+	}
+	return h.items[i].index < h.items[j].index
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(*mergeSource)) }
+
+func (h *mergeHeap) Pop() any {
+	old := h.items
+	item := old[len(old)-1]
+	h.items = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// randomInput генерирует строки с повторяющимися ключами
+func randomInput(r *rand.Rand, n int) string {
+	words := []string{"apple", "banana", "cherry", "Jan", "Dec", "10K", "2M", "-3", "4.5"}
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%s\t%d\t%s\n", words[r.Intn(len(words))], r.Intn(50), words[r.Intn(len(words))])
+	}
+	return b.String()
+}
+
+// TestSortStreamExternal проверяет, что внешняя сортировка совпадает с сортировкой в памяти
+func TestSortStreamExternal(t *testing.T) {
+	input := randomInput(rand.New(rand.NewSource(1)), 3000)
+
+	tests := []struct {
+		name string
+		opts SortOptions
+	}{
+		{name: "вся строка", opts: SortOptions{}},
+		{name: "числовой столбец", opts: SortOptions{keys: []keySpec{{startField: 2, endField: 2}}, numeric: true}},
+		{name: "обратный порядок", opts: SortOptions{keys: []keySpec{{startField: 1, endField: 1}}, reverse: true}},
+		{name: "уникальные", opts: SortOptions{keys: []keySpec{{startField: 3, endField: 3}}, unique: true}},
+		{name: "месяцы", opts: SortOptions{keys: []keySpec{{startField: 1, endField: 1}}, monthSort: true}},
+		{name: "размеры", opts: SortOptions{keys: []keySpec{{startField: 3, endField: 3}}, humanNumeric: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var want bytes.Buffer
+			if err := sortStream(strings.NewReader(input), &want, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+
+			dir := t.TempDir()
+			opts := test.opts
+			opts.bufferSize = 4 << 10
+			opts.tempDir = dir
+			var got bytes.Buffer
+			if err := sortStream(strings.NewReader(input), &got, opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("результат внешней сортировки отличается от сортировки в памяти")
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("ожидалось удаление временных файлов, осталось %d", len(entries))
+			}
+		})
+	}
+}
+
+// TestSpillBudget проверяет, что при числовых ключах -S ограничивает оценку
+// Line.size, которая учитывает разобранные значения, а не только текст:
+// каждая часть во временном файле укладывается в буфер, а число частей
+// соответствует общей оценке входа.
+func TestSpillBudget(t *testing.T) {
+	opts := SortOptions{
+		keys:       []keySpec{{startField: 1, endField: 1}, {startField: 2, endField: 2}},
+		numeric:    true,
+		bufferSize: 16 << 10,
+		tempDir:    t.TempDir(),
+	}
+	opts.resolveKeys()
+	s := &externalSorter{opts: opts}
+	defer s.cleanup()
+
+	// Строка с двумя числовыми ключами — это Line, её текст и по keyValue
+	// с numericValue на каждый ключ
+	perValue := int64(unsafe.Sizeof(keyValue{}) + unsafe.Sizeof(numericValue{}))
+	var total, longest int64
+	for i := 0; i < 5000; i++ {
+		text := fmt.Sprintf("%d\t-%d.%d", i*7919%1000000, i%1000, i*31%1000)
+		line := makeLine(text, opts)
+		if min := int64(unsafe.Sizeof(line)) + int64(len(text)) + 2*perValue; line.size() < min {
+			t.Fatalf("строка %q оценена в %d байт, ожидалось не меньше %d", text, line.size(), min)
+		}
+		total += line.size()
+		longest = max(longest, line.size())
+		if err := s.add(line); err != nil {
+			t.Fatalf("ожидалось nil, получено: %v", err)
+		}
+	}
+
+	// Часть сбрасывается, как только оценка достигает буфера, поэтому
+	// каждая часть занимает от bufferSize до bufferSize+longest
+	if n, low, high := int64(len(s.runs)), total/(opts.bufferSize+longest), total/opts.bufferSize; n < low || n > high {
+		t.Errorf("ожидалось от %d до %d временных файлов, получено %d", low, high, n)
+	}
+	for _, name := range s.runs {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var size int64
+		for _, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			size += makeLine(text, opts).size()
+		}
+		if size < opts.bufferSize || size >= opts.bufferSize+longest {
+			t.Errorf("часть оценена в %d байт при буфере %d", size, opts.bufferSize)
+		}
+	}
+}
+
+// TestSortStreamLastLine проверяет строку без завершающего перевода строки
+func TestSortStreamLastLine(t *testing.T) {
+	var out bytes.Buffer
+	if err := sortStream(strings.NewReader("b\r\na\nc"), &out, SortOptions{}); err != nil {
+		t.Fatalf("ожидалось nil, получено: %v", err)
+	}
+	if out.String() != "a\nb\nc\n" {
+		t.Errorf("ожидалось %q, получено %q", "a\nb\nc\n", out.String())
+	}
+}
+
+// TestParseBufferSize тестирует разбор размера буфера
+func TestParseBufferSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{input: "", expected: defaultBufferSize},
+		{input: "100", expected: 100 << 10},
+		{input: "512b", expected: 512},
+		{input: "64M", expected: 64 << 20},
+		{input: "2G", expected: 2 << 30},
+		{input: "0", wantErr: true},
+		{input: "M", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, err := parseBufferSize(test.input)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ожидалась ошибка, получено %d", result)
+				}
+				return
+			}
+			if err != nil || result != test.expected {
+				t.Errorf("ожидалось %d, получено %d (%v)", test.expected, result, err)
+			}
+		})
+	}
+}
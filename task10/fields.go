@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseSeparator разбирает значение -t: один символ, а также \t и \0.
+func parseSeparator(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case `\t`:
+		return '\t', nil
+	case `\0`:
+		return -1, nil
+	}
+	sep, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || sep == utf8.RuneError {
+		return 0, fmt.Errorf("multi-character separator %q", s)
+	}
+	return sep, nil
+}
+
+// isBlank сообщает, является ли байт пробелом или табуляцией.
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// splitFields возвращает границы полей строки [начало, конец) в байтах.
+func splitFields(line string, opts SortOptions) [][2]int {
+	switch {
+	case opts.csv && opts.separator == 0:
+		return csvFields(line, ',')
+	case opts.csv:
+		return csvFields(line, opts.separator)
+	case opts.separator != 0:
+		return separatedFields(line, opts.separator)
+	default:
+		return blankFields(line)
+	}
+}
+
+// sepString возвращает разделитель в виде строки; -1 означает NUL.
+func sepString(sep rune) string {
+	if sep < 0 {
+		return "\x00"
+	}
+	return string(sep)
+}
+
+// separatedFields делит строку по каждому вхождению разделителя, как GNU sort -t.
+func separatedFields(line string, sep rune) [][2]int {
+	delim := sepString(sep)
+	var bounds [][2]int
+	start := 0
+	for {
+		i := strings.Index(line[start:], delim)
+		if i < 0 {
+			return append(bounds, [2]int{start, len(line)})
+		}
+		bounds = append(bounds, [2]int{start, start + i})
+		start += i + len(delim)
+	}
+}
+
+// blankFields делит строку как GNU sort без -t: поле начинается
+// с пробелов, за которыми идут непробельные символы.
+func blankFields(line string) [][2]int {
+	var bounds [][2]int
+	start := 0
+	for start < len(line) {
+		end := start
+		for end < len(line) && isBlank(line[end]) {
+			end++
+		}
+		for end < len(line) && !isBlank(line[end]) {
+			end++
+		}
+		bounds = append(bounds, [2]int{start, end})
+		start = end
+	}
+	if len(bounds) == 0 {
+		bounds = append(bounds, [2]int{0, 0})
+	}
+	return bounds
+}
+
+// csvFields делит строку как запись CSV. Поле в кавычках может содержать
+// разделитель; границы такого поля не включают внешние кавычки.
+// Переводы строк внутри кавычек не поддерживаются: запись — одна строка.
+func csvFields(line string, sep rune) [][2]int {
+	delim := sepString(sep)
+	var bounds [][2]int
+	start := 0
+	for {
+		if strings.HasPrefix(line[start:], `"`) {
+			end := closingQuote(line, start+1)
+			bounds = append(bounds, [2]int{start + 1, end})
+			// Всё после закрывающей кавычки до разделителя отбрасывается
+			start = min(end+1, len(line))
+			i := strings.Index(line[start:], delim)
+			if i < 0 {
+				return bounds
+			}
+			start += i + len(delim)
+			continue
+		}
+
+		i := strings.Index(line[start:], delim)
+		if i < 0 {
+			return append(bounds, [2]int{start, len(line)})
+		}
+		bounds = append(bounds, [2]int{start, start + i})
+		start += i + len(delim)
+	}
+}
+
+// closingQuote возвращает позицию закрывающей кавычки поля, начинающегося
+// с from; удвоенная кавычка "" считается частью значения.
+func closingQuote(line string, from int) int {
+	for i := from; i < len(line); i++ {
+		if line[i] != '"' {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == '"' {
+			i++
+			continue
+		}
+		return i
+	}
+	return len(line)
+}
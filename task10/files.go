@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// openInputs открывает входные файлы; "-" и пустой список означают STDIN.
+// Возвращённая функция закрывает все открытые файлы.
+func openInputs(names []string) ([]io.Reader, func(), error) {
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	var files []*os.File
+	closeAll := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	inputs := make([]io.Reader, 0, len(names))
+	for _, name := range names {
+		if name == "-" {
+			inputs = append(inputs, os.Stdin)
+			continue
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, file)
+		inputs = append(inputs, file)
+	}
+	return inputs, closeAll, nil
+}
+
+// outputFile пишет результат во временный файл рядом с целевым и заменяет
+// им целевой только в Commit. Поэтому -o может указывать на один из входов:
+// вход дочитывается до того, как его содержимое будет заменено.
+type outputFile struct {
+	*os.File
+	target string
+	mode   os.FileMode
+}
+
+// createOutput создаёт временный файл для вывода в name. Если name — не
+// обычный файл (например, /dev/stdout), вывод идёт в него напрямую.
+func createOutput(name string) (*outputFile, error) {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(name); err == nil {
+		if !info.Mode().IsRegular() {
+			file, err := os.OpenFile(name, os.O_WRONLY, 0)
+			if err != nil {
+				return nil, err
+			}
+			return &outputFile{File: file}, nil
+		}
+		mode = info.Mode().Perm()
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".sort-*")
+	if err != nil {
+		return nil, err
+	}
+	return &outputFile{File: file, target: name, mode: mode}, nil
+}
+
+// Commit закрывает временный файл и атомарно переименовывает его в целевой.
+func (o *outputFile) Commit() error {
+	if err := o.Close(); err != nil {
+		o.Abort()
+		return err
+	}
+	if o.target == "" {
+		return nil
+	}
+	if err := os.Chmod(o.Name(), o.mode); err != nil {
+		o.Abort()
+		return err
+	}
+	if err := os.Rename(o.Name(), o.target); err != nil {
+		o.Abort()
+		return err
+	}
+	return nil
+}
+
+// Abort удаляет временный файл, не трогая целевой.
+func (o *outputFile) Abort() {
+	o.Close()
+	if o.target != "" {
+		os.Remove(o.Name())
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSortReaders проверяет сортировку нескольких входов, включая вход без перевода строки в конце
+func TestSortReaders(t *testing.T) {
+	inputs := []io.Reader{strings.NewReader("c\na"), strings.NewReader("b\n"), strings.NewReader("")}
+	var out bytes.Buffer
+	if err := sortReaders(inputs, &out, SortOptions{}); err != nil {
+		t.Fatalf("ожидалось nil, получено: %v", err)
+	}
+	if out.String() != "a\nb\nc\n" {
+		t.Errorf("ожидалось %q, получено %q", "a\nb\nc\n", out.String())
+	}
+}
+
+// TestMergeStreams тестирует слияние отсортированных входов (-m)
+func TestMergeStreams(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []string
+		opts     SortOptions
+		expected string
+	}{
+		{
+			name:     "два входа",
+			inputs:   []string{"a\nc\ne", "b\nd\n"},
+			expected: "a\nb\nc\nd\ne\n",
+		},
+		{
+			name:     "равные ключи в порядке входов",
+			inputs:   []string{"1 x\n2 x\n", "1 y\n3 y\n", "1 z\n"},
+			opts:     SortOptions{stable: true, keys: []keySpec{{startField: 1, endField: 1, numeric: true}}},
+			expected: "1 x\n1 y\n1 z\n2 x\n3 y\n",
+		},
+		{
+			name:     "уникальные по убыванию",
+			inputs:   []string{"c\nb\na\n", "c\na\n"},
+			opts:     SortOptions{reverse: true, unique: true},
+			expected: "c\nb\na\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var inputs []io.Reader
+			for _, input := range test.inputs {
+				inputs = append(inputs, strings.NewReader(input))
+			}
+			var out bytes.Buffer
+			if err := mergeStreams(inputs, &out, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if out.String() != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+// TestOutputOverwritesInput проверяет, что -o может указывать на входной файл
+func TestOutputOverwritesInput(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.txt")
+	other := filepath.Join(dir, "other.txt")
+	if err := os.WriteFile(name, []byte("d\nb\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, []byte("c\na\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs, closeInputs, err := openInputs([]string{name, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeInputs()
+	out, err := createOutput(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sortReaders(inputs, out, SortOptions{}); err != nil {
+		t.Fatalf("ожидалось nil, получено: %v", err)
+	}
+	if err := out.Commit(); err != nil {
+		t.Fatalf("ожидалось nil, получено: %v", err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a\nb\nc\nd\n" {
+		t.Errorf("ожидалось %q, получено %q", "a\nb\nc\nd\n", data)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("ожидались права 0640, получено %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("ожидалось 2 файла в каталоге, получено %d", len(entries))
+	}
+}
+
+// TestOutputAbort проверяет, что при ошибке целевой файл не меняется
+func TestOutputAbort(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(name, []byte("keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := createOutput(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.WriteString("partial")
+	out.Abort()
+
+	data, _ := os.ReadFile(name)
+	if string(data) != "keep\n" {
+		t.Errorf("ожидалось %q, получено %q", "keep\n", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("ожидалось удаление временного файла, файлов в каталоге: %d", len(entries))
+	}
+}
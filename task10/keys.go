@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keySpec описывает ключ сортировки в формате GNU -k POS1[,POS2][OPTS].
+// Поля и символы нумеруются с 1, символы считаются в рунах.
+type keySpec struct {
+	startField int // номер поля начала ключа
+	startChar  int // символ в поле начала, 0 — с начала поля
+	endField   int // номер поля конца ключа, 0 — до конца строки
+	endChar    int // последний символ в поле конца, 0 — до конца поля
+
+	// --key: путь JSON (.user.age) или имя столбца CSV; для CSV до чтения
+	// заголовка, после — заменяется номером поля
+	name string
+
+	// Модификаторы ключа; если не задан ни один, наследуются глобальные опции
+	numeric      bool // n
+	reverse      bool // r
+	monthSort    bool // M
+	ignoreBlanks bool // b
+	humanNumeric bool // h
+	foldCase     bool // f
+	dictionary   bool // d
+	general      bool // g
+	version      bool // V
+	random       bool // R
+}
+
+// hasModifiers сообщает, заданы ли у ключа собственные модификаторы.
+func (k keySpec) hasModifiers() bool {
+	return k.numeric || k.reverse || k.monthSort || k.ignoreBlanks || k.humanNumeric ||
+		k.foldCase || k.dictionary || k.general || k.version || k.random
+}
+
+// keyType — способ сравнения значений ключа.
+type keyType uint8
+
+const (
+	textKey    keyType = iota // строки, с учётом -f, -d и --locale
+	numericKey                // -n
+	generalKey                // -g
+	humanKey                  // -h
+	monthKey                  // -M
+	versionKey                // -V
+	randomKey                 // -R
+)
+
+// kind возвращает тип сравнения ключа; несовместимые модификаторы
+// отклоняет check.
+func (k keySpec) kind() keyType {
+	switch {
+	case k.numeric:
+		return numericKey
+	case k.general:
+		return generalKey
+	case k.humanNumeric:
+		return humanKey
+	case k.monthSort:
+		return monthKey
+	case k.version:
+		return versionKey
+	case k.random:
+		return randomKey
+	}
+	return textKey
+}
+
+// check проверяет, что у ключа не больше одного типа сравнения, а -d
+// не сочетается с числовыми типами, как в GNU sort.
+func (k keySpec) check() error {
+	numeric, types := 0, 0
+	for _, set := range []bool{k.numeric, k.general, k.humanNumeric, k.monthSort, k.version, k.random} {
+		if set {
+			types++
+		}
+	}
+	for _, set := range []bool{k.numeric, k.general, k.humanNumeric, k.monthSort} {
+		if set {
+			numeric++
+		}
+	}
+	if types < 2 && !(k.dictionary && numeric > 0) {
+		return nil
+	}
+
+	var letters strings.Builder
+	for _, opt := range []struct {
+		set    bool
+		letter byte
+	}{
+		{k.dictionary, 'd'}, {k.general, 'g'}, {k.humanNumeric, 'h'},
+		{k.monthSort, 'M'}, {k.numeric, 'n'}, {k.random, 'R'}, {k.version, 'V'},
+	} {
+		if opt.set {
+			letters.WriteByte(opt.letter)
+		}
+	}
+	return fmt.Errorf("options '-%s' are incompatible", letters.String())
+}
+
+// parseKeySpec разбирает описание ключа, например "2,2n", "1,1r" или "3.2,3.5".
+func parseKeySpec(s string) (keySpec, error) {
+	var k keySpec
+	pos1, pos2, hasEnd := strings.Cut(s, ",")
+
+	var err error
+	k.startField, k.startChar, err = parseKeyPos(pos1, &k)
+	if err != nil {
+		return keySpec{}, fmt.Errorf("invalid key %q: %v", s, err)
+	}
+	if k.startChar == 0 && strings.Contains(pos1, ".") {
+		return keySpec{}, fmt.Errorf("invalid key %q: character offset is zero", s)
+	}
+	if hasEnd {
+		k.endField, k.endChar, err = parseKeyPos(pos2, &k)
+		if err != nil {
+			return keySpec{}, fmt.Errorf("invalid key %q: %v", s, err)
+		}
+	}
+	return k, nil
+}
+
+// parseKeyPos разбирает позицию F[.C][OPTS] и добавляет модификаторы в k.
+func parseKeyPos(s string, k *keySpec) (field, char int, err error) {
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if end < 0 {
+		end = len(s)
+	}
+	pos, mods := s[:end], s[end:]
+
+	fieldStr, charStr, hasChar := strings.Cut(pos, ".")
+	field, err = strconv.Atoi(fieldStr)
+	if err != nil || field <= 0 {
+		return 0, 0, fmt.Errorf("invalid field number %q", fieldStr)
+	}
+	if hasChar {
+		char, err = strconv.Atoi(charStr)
+		if err != nil || char < 0 {
+			return 0, 0, fmt.Errorf("invalid character offset %q", charStr)
+		}
+	}
+
+	if err := parseModifiers(mods, k); err != nil {
+		return 0, 0, err
+	}
+	return field, char, nil
+}
+
+// parseModifiers добавляет в k модификаторы ключа, например "nr".
+func parseModifiers(mods string, k *keySpec) error {
+	for _, m := range mods {
+		switch m {
+		case 'n':
+			k.numeric = true
+		case 'r':
+			k.reverse = true
+		case 'M':
+			k.monthSort = true
+		case 'b':
+			k.ignoreBlanks = true
+		case 'h':
+			k.humanNumeric = true
+		case 'f':
+			k.foldCase = true
+		case 'd':
+			k.dictionary = true
+		case 'g':
+			k.general = true
+		case 'V':
+			k.version = true
+		case 'R':
+			k.random = true
+		default:
+			return fmt.Errorf("unknown modifier %q", m)
+		}
+	}
+	return nil
+}
+
+// keyList — значение флага -k, который можно указать несколько раз.
+type keyList []keySpec
+
+// String возвращает пустую строку, пока ключи не заданы, чтобы в справке
+// у -k и --key не выводилось значение по умолчанию.
+func (l *keyList) String() string {
+	if l == nil || len(*l) == 0 {
+		return ""
+	}
+	return fmt.Sprint(len(*l), " keys")
+}
+
+func (l *keyList) Set(s string) error {
+	k, err := parseKeySpec(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, k)
+	return nil
+}
+
+// keyCount возвращает число ключей сравнения; без -k ключом служит вся строка.
+func (opts SortOptions) keyCount() int {
+	return max(1, len(opts.keys))
+}
+
+// keyAt возвращает i-й ключ с учётом глобальных опций.
+func (opts SortOptions) keyAt(i int) keySpec {
+	k := keySpec{startField: 1}
+	if len(opts.keys) > 0 {
+		k = opts.keys[i]
+	}
+	if !k.hasModifiers() {
+		k.numeric = opts.numeric
+		k.reverse = opts.reverse
+		k.monthSort = opts.monthSort
+		k.ignoreBlanks = opts.ignoreBlanks
+		k.humanNumeric = opts.humanNumeric
+		k.foldCase = opts.foldCase
+		k.dictionary = opts.dictionary
+		k.general = opts.general
+		k.version = opts.version
+		k.random = opts.random
+	}
+	return k
+}
+
+// resolveKeys заполняет opts.resolved ключами с учётом глобальных опций.
+// Вызывается один раз перед разбором входа, чтобы makeLine и compareKeys
+// не собирали ключи заново для каждой строки и каждого сравнения.
+func (opts *SortOptions) resolveKeys() {
+	opts.resolved = make([]keySpec, opts.keyCount())
+	for i := range opts.resolved {
+		opts.resolved[i] = opts.keyAt(i)
+	}
+}
+
+// validate проверяет глобальные опции и все ключи на несовместимость.
+func (opts SortOptions) validate() error {
+	if opts.jsonl && opts.csv {
+		return fmt.Errorf("options '--jsonl' and '--csv' are incompatible")
+	}
+	if opts.hasNamedKeys() && !opts.jsonl && !opts.csv {
+		return fmt.Errorf("option '--key' requires '--jsonl' or '--csv'")
+	}
+	if opts.head < 0 || opts.tail < 0 {
+		return fmt.Errorf("invalid number of lines")
+	}
+	if opts.head > 0 && opts.tail > 0 {
+		return fmt.Errorf("options '--head' and '--tail' are incompatible")
+	}
+	if (opts.head > 0 || opts.tail > 0) && opts.unique {
+		return fmt.Errorf("options '--head'/'--tail' and '-u' are incompatible")
+	}
+	global := opts
+	global.keys = nil
+	if err := global.keyAt(0).check(); err != nil {
+		return err
+	}
+	for i := range opts.keys {
+		if err := opts.keyAt(i).check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractKey извлекает ключ k из строки с границами полей bounds
+func extractKey(line string, bounds [][2]int, k keySpec) string {
+	start, end := keyRange(line, bounds, k)
+	if k.name != "" && strings.IndexByte(line[start:end], '\\') >= 0 {
+		return unquoteJSON(line, start, end)
+	}
+	return line[start:end]
+}
+
+// keyRange возвращает байтовые границы ключа k в строке.
+func keyRange(line string, bounds [][2]int, k keySpec) (start, end int) {
+	if k.name != "" {
+		start, end = jsonRange(line, k.name)
+	} else {
+		start, end = fieldRange(line, bounds, k)
+	}
+	if end <= start {
+		return start, start
+	}
+
+	if k.ignoreBlanks {
+		key := line[start:end]
+		trimmed := strings.TrimLeftFunc(key, unicode.IsSpace)
+		start += len(key) - len(trimmed)
+		end = start + len(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+	}
+	return start, end
+}
+
+// fieldRange возвращает байтовые границы ключа, заданного номерами полей.
+func fieldRange(line string, bounds [][2]int, k keySpec) (start, end int) {
+	if k.startField > len(bounds) {
+		return len(line), len(line)
+	}
+
+	start = k.fieldOffset(line, bounds[k.startField-1], k.startChar-1)
+	end = len(line)
+	if k.endField > 0 && k.endField <= len(bounds) {
+		field := bounds[k.endField-1]
+		end = field[1]
+		if k.endChar > 0 {
+			end = k.fieldOffset(line, field, k.endChar)
+		}
+	}
+	return start, end
+}
+
+// fieldOffset возвращает байтовое смещение n-го символа поля, не выходя за его конец.
+func (k keySpec) fieldOffset(line string, field [2]int, n int) int {
+	offset := field[0]
+	if k.ignoreBlanks {
+		for offset < field[1] && isBlank(line[offset]) {
+			offset++
+		}
+	}
+	for ; n > 0 && offset < field[1]; n-- {
+		_, size := utf8.DecodeRuneInString(line[offset:field[1]])
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// numberFormat — разделители в записи чисел, принятые в локали (--locale).
+type numberFormat struct {
+	decimal  rune // десятичный разделитель, 0 — точка
+	grouping rune // разделитель разрядов, 0 — не допускается
+}
+
+// point возвращает десятичный разделитель.
+func (f numberFormat) point() rune {
+	if f.decimal == 0 {
+		return '.'
+	}
+	return f.decimal
+}
+
+// localeNumbers определяет разделители чисел локали, форматируя пробное
+// число 1234.5 (в некоторых локалях четыре цифры не делятся на разряды).
+// Для "", "C" и "POSIX" разделитель — точка, разряды не делятся.
+func localeNumbers(locale string) (numberFormat, error) {
+	tag, ok, err := parseLocale(locale)
+	if err != nil || !ok {
+		return numberFormat{}, err
+	}
+	sample := []rune(message.NewPrinter(tag).Sprint(number.Decimal(1234.5)))
+	if len(sample) < 6 {
+		return numberFormat{}, nil
+	}
+	f := numberFormat{decimal: sample[len(sample)-2]}
+	if len(sample) == 7 {
+		f.grouping = sample[1]
+	}
+	return f, nil
+}
+
+// humanSuffixes — суффиксы -h в порядке возрастания степени.
+const humanSuffixes = "KMGTPEZYRQ"
+
+// humanLowerSuffixes — суффиксы, которые -h принимает и строчными, как
+// прежде: 5m — то же, что 5M.
+const humanLowerSuffixes = "kmgt"
+
+// numericValue — значение ключа при сортировке -n и -h. Цифры хранятся
+// десятичной записью, поэтому числа любой длины сравниваются точно.
+type numericValue struct {
+	negative bool
+	integer  string // целая часть без ведущих нулей и разделителей разрядов
+	fraction string // дробная часть без хвостовых нулей
+
+	// Только для -h: суффикс K (1), M (2), ... и величина с его учётом.
+	// K, M, G — степени 1000, Ki, Mi, Gi — степени 1024.
+	scale     int
+	binary    bool
+	magnitude float64
+}
+
+// parseNumeric разбирает число в начале ключа так же, как GNU sort -n:
+// пробелы, необязательный минус, цифры с разделителями разрядов локали,
+// десятичный разделитель и дробная часть. Знак '+', экспонента и всё после
+// числа игнорируются; ключ без цифр равен нулю. При human после числа
+// разбирается суффикс -h. Возвращает также границы разобранной части ключа,
+// start == end, если число не найдено.
+func parseNumeric(s string, f numberFormat, human bool) (v numericValue, start, end int) {
+	for start < len(s) && isBlank(s[start]) {
+		start++
+	}
+	i := start
+	if i < len(s) && s[i] == '-' {
+		v.negative = true
+		i++
+	}
+
+	intStart, grouped := i, false
+	for i < len(s) {
+		if isDigit(s[i]) {
+			i++
+			continue
+		}
+		// Разделитель разрядов допускается только между цифрами
+		if r, size := utf8.DecodeRuneInString(s[i:]); f.grouping != 0 && r == f.grouping &&
+			i > intStart && i+size < len(s) && isDigit(s[i+size]) {
+			i += size
+			grouped = true
+			continue
+		}
+		break
+	}
+	v.integer = s[intStart:i]
+	if grouped {
+		v.integer = strings.Map(func(r rune) rune {
+			if r == f.grouping {
+				return -1
+			}
+			return r
+		}, v.integer)
+	}
+
+	if r, size := utf8.DecodeRuneInString(s[i:]); r == f.point() {
+		j := i + size
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		v.fraction = s[i+size : j]
+		if v.integer != "" || v.fraction != "" {
+			i = j
+		}
+	}
+	if v.integer == "" && v.fraction == "" {
+		return numericValue{}, start, start
+	}
+
+	v.integer = strings.TrimLeft(v.integer, "0")
+	v.fraction = strings.TrimRight(v.fraction, "0")
+	if v.integer == "" && v.fraction == "" {
+		v.negative = false
+	}
+
+	if human {
+		if i < len(s) {
+			v.scale = strings.IndexByte(humanSuffixes, s[i]) + 1
+			if v.scale == 0 {
+				v.scale = strings.IndexByte(humanLowerSuffixes, s[i]) + 1
+			}
+			if v.scale > 0 {
+				i++
+				if i < len(s) && s[i] == 'i' {
+					v.binary = true
+					i++
+				}
+			}
+		}
+		v.magnitude = v.humanMagnitude()
+	}
+	return v, start, i
+}
+
+// humanMagnitude возвращает величину числа -h с учётом суффикса.
+func (v numericValue) humanMagnitude() float64 {
+	mantissa, _ := strconv.ParseFloat(v.integer+"."+v.fraction, 64)
+	base := 1000.0
+	if v.binary {
+		base = 1024
+	}
+	mantissa *= math.Pow(base, float64(v.scale))
+	if v.negative {
+		mantissa = -mantissa
+	}
+	return mantissa
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compareNumeric точно сравнивает значения -n. Ведущие нули parseNumeric
+// уже убрал, поэтому целая часть длиннее — значит, число больше по модулю.
+func compareNumeric(a, b *numericValue) int {
+	if a.negative != b.negative {
+		if a.negative {
+			return -1
+		}
+		return 1
+	}
+	result := cmp.Compare(len(a.integer), len(b.integer))
+	if result == 0 {
+		result = strings.Compare(a.integer, b.integer)
+	}
+	if result == 0 {
+		result = strings.Compare(a.fraction, b.fraction)
+	}
+	if a.negative {
+		result = -result
+	}
+	return result
+}
+
+// compareHuman сравнивает значения -h по величине: 1K < 1Ki < 1M, а 1000K
+// и 1M равны. Числа с одинаковым суффиксом сравниваются точно.
+func compareHuman(a, b *numericValue) int {
+	if result := cmp.Compare(a.magnitude, b.magnitude); result != 0 {
+		return result
+	}
+	if a.scale == b.scale && a.binary == b.binary {
+		return compareNumeric(a, b)
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestCompareNumericKeys тестирует сравнение ключей -n и -h
+func TestCompareNumericKeys(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		human    bool
+		format   numberFormat
+		expected int
+	}{
+		{a: "9", b: "10", expected: -1},
+		{a: "-10", b: "-9", expected: -1},
+		{a: "-0", b: "0.000", expected: 0},
+		{a: "N/A", b: "0", expected: 0},
+		{a: "N/A", b: "-1", expected: 1},
+		{a: "12abc", b: "12", expected: 0},
+		{a: "+5", b: "0", expected: 0},
+		{a: "1e3", b: "1", expected: 0},
+		{a: ".5", b: "0.05", expected: 1},
+		{a: "123456789012345678901", b: "123456789012345678902", expected: -1},
+		{a: "1,5", b: "1.4", format: numberFormat{decimal: ','}, expected: 1},
+		{a: "1 234,5", b: "999", format: numberFormat{decimal: ',', grouping: ' '}, expected: 1},
+		{a: "1,234", b: "2", expected: -1},
+		{a: "1,234", b: "2", format: numberFormat{grouping: ','}, expected: 1},
+		{a: "1K", b: "1Ki", human: true, expected: -1},
+		{a: "1023Ki", b: "1Mi", human: true, expected: -1},
+		{a: "1000K", b: "1M", human: true, expected: 0},
+		{a: "999P", b: "1E", human: true, expected: -1},
+		{a: "1.5k", b: "1499", human: true, expected: 1},
+		{a: "-1G", b: "5", human: true, expected: -1},
+		{a: "N/A", b: "1", human: true, expected: -1},
+		{a: "5m", b: "5M", human: true, expected: 0},
+		{a: "5m", b: "4999k", human: true, expected: 1},
+		{a: "1t", b: "999g", human: true, expected: 1},
+		{a: "2gi", b: "2Gi", human: true, expected: 0},
+		{a: "1p", b: "2", human: true, expected: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a, _, _ := parseNumeric(test.a, test.format, test.human)
+			b, _, _ := parseNumeric(test.b, test.format, test.human)
+			compare := compareNumeric
+			if test.human {
+				compare = compareHuman
+			}
+			if result := compare(&a, &b); result != test.expected {
+				t.Errorf("ожидалось %d, получено %d", test.expected, result)
+			}
+			if result := compare(&b, &a); result != -test.expected {
+				t.Errorf("обратное сравнение: ожидалось %d, получено %d", -test.expected, result)
+			}
+		})
+	}
+}
+
+// TestLocaleNumbers тестирует определение разделителей чисел по локали
+func TestLocaleNumbers(t *testing.T) {
+	tests := []struct {
+		locale   string
+		expected numberFormat
+	}{
+		{locale: "", expected: numberFormat{}},
+		{locale: "C", expected: numberFormat{}},
+		{locale: "en_US.UTF-8", expected: numberFormat{decimal: '.', grouping: ','}},
+		{locale: "de", expected: numberFormat{decimal: ',', grouping: '.'}},
+		{locale: "ru_RU", expected: numberFormat{decimal: ',', grouping: ' '}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.locale, func(t *testing.T) {
+			result, err := localeNumbers(test.locale)
+			if err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("ожидалось %q, получено %q", []rune{test.expected.decimal, test.expected.grouping},
+					[]rune{result.decimal, result.grouping})
+			}
+		})
+	}
+}
+
+// TestValidateOptions тестирует отказ от несовместимых типов сравнения
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     SortOptions
+		expected string
+	}{
+		{name: "один тип", opts: SortOptions{numeric: true, reverse: true, foldCase: true}},
+		{name: "-dV допустимо", opts: SortOptions{dictionary: true, version: true}},
+		{name: "-fR допустимо", opts: SortOptions{foldCase: true, random: true}},
+		{
+			name:     "-n и -R",
+			opts:     SortOptions{numeric: true, random: true},
+			expected: "options '-nR' are incompatible",
+		},
+		{
+			name:     "--head и -u",
+			opts:     SortOptions{head: 10, unique: true},
+			expected: "options '--head'/'--tail' and '-u' are incompatible",
+		},
+		{
+			name:     "-n и -M",
+			opts:     SortOptions{numeric: true, monthSort: true},
+			expected: "options '-Mn' are incompatible",
+		},
+		{
+			name:     "-d и -g",
+			opts:     SortOptions{dictionary: true, general: true},
+			expected: "options '-dg' are incompatible",
+		},
+		{
+			name:     "ключ с -hV",
+			opts:     SortOptions{keys: []keySpec{{startField: 1}, {startField: 2, humanNumeric: true, version: true}}},
+			expected: "options '-hV' are incompatible",
+		},
+		{
+			name:     "глобальные опции при ключах с модификаторами",
+			opts:     SortOptions{numeric: true, general: true, keys: []keySpec{{startField: 1, reverse: true}}},
+			expected: "options '-gn' are incompatible",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.opts.validate()
+			if test.expected == "" {
+				if err != nil {
+					t.Errorf("ожидалось nil, получено: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("ожидалось %q, получено %v", test.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// minParallelPart — меньше стольких строк на поток сортировка идёт в одном потоке
+const minParallelPart = 4096
+
+// sortSerial сортирует lines в одном потоке. Пока строки с равными ключами
+// сравниваются целиком, порядок полный и хватает sort.Slice; при -s и -u
+// нужна стабильная сортировка.
+func sortSerial(lines []Line, opts SortOptions) {
+	less := func(i, j int) bool {
+		return compareLines(lines[i], lines[j], opts) < 0
+	}
+	if opts.lastResort() {
+		sort.Slice(lines, less)
+	} else {
+		sort.SliceStable(lines, less)
+	}
+}
+
+// sortLinesParallel сортирует части lines в workers потоках и сливает их
+// попарно. Слияние берёт строку из левой части при равных ключах,
+// поэтому результат совпадает с однопоточной сортировкой.
+func sortLinesParallel(lines []Line, opts SortOptions, workers int) {
+	workers = min(workers, len(lines)/minParallelPart)
+	if workers < 2 {
+		sortSerial(lines, opts)
+		return
+	}
+
+	// bounds[i] — начало i-й части, bounds[len(bounds)-1] — конец среза
+	bounds := make([]int, workers+1)
+	for i := range bounds {
+		bounds[i] = i * len(lines) / workers
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		part := lines[bounds[i]:bounds[i+1]]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sortSerial(part, opts)
+		}()
+	}
+	wg.Wait()
+
+	src, dst := lines, make([]Line, len(lines))
+	for len(bounds) > 2 {
+		next := []int{0}
+		for i := 0; i+1 < len(bounds); i += 2 {
+			lo := bounds[i]
+			if i+2 >= len(bounds) {
+				// Нечётная часть переходит на следующий уровень без слияния
+				copy(dst[lo:], src[lo:bounds[i+1]])
+				next = append(next, bounds[i+1])
+				continue
+			}
+			mid, hi := bounds[i+1], bounds[i+2]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				mergeLines(dst[lo:hi], src[lo:mid], src[mid:hi], opts)
+			}()
+			next = append(next, hi)
+		}
+		wg.Wait()
+		src, dst, bounds = dst, src, next
+	}
+	if &src[0] != &lines[0] {
+		copy(lines, src)
+	}
+}
+
+// mergeLines сливает отсортированные left и right в dst, отдавая
+// предпочтение left при равных ключах.
+func mergeLines(dst, left, right []Line, opts SortOptions) {
+	i, j, k := 0, 0, 0
+	for i < len(left) && j < len(right) {
+		if compareLines(right[j], left[i], opts) < 0 {
+			dst[k] = right[j]
+			j++
+		} else {
+			dst[k] = left[i]
+			i++
+		}
+		k++
+	}
+	k += copy(dst[k:], left[i:])
+	copy(dst[k:], right[j:])
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var benchLines = flag.Int("bench-lines", 1_000_000, "число строк в бенчмарках сортировки")
+
+// TestSortParallel проверяет, что параллельная сортировка совпадает с однопоточной побайтно
+func TestSortParallel(t *testing.T) {
+	input := randomInput(rand.New(rand.NewSource(2)), 30_000)
+
+	tests := []struct {
+		name string
+		opts SortOptions
+	}{
+		{name: "вся строка", opts: SortOptions{}},
+		{name: "равные ключи", opts: SortOptions{keys: []keySpec{{startField: 1, endField: 1}}}},
+		{name: "числа по убыванию", opts: SortOptions{keys: []keySpec{{startField: 2, endField: 2, numeric: true, reverse: true}}}},
+		{name: "уникальные", opts: SortOptions{unique: true, keys: []keySpec{{startField: 3, endField: 3}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var want bytes.Buffer
+			if err := sortStream(strings.NewReader(input), &want, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			for _, workers := range []int{2, 3, 4, 7} {
+				opts := test.opts
+				opts.parallel = workers
+				var got bytes.Buffer
+				if err := sortStream(strings.NewReader(input), &got, opts); err != nil {
+					t.Fatalf("ожидалось nil, получено: %v", err)
+				}
+				if !bytes.Equal(got.Bytes(), want.Bytes()) {
+					t.Errorf("--parallel %d: результат отличается от однопоточного", workers)
+				}
+			}
+		})
+	}
+}
+
+// benchSort сортирует *benchLines строк с числовым и текстовым ключом
+// в workers потоках: с последним сравнением строк целиком и стабильно (-s).
+func benchSort(b *testing.B, workers int) {
+	keys := []keySpec{{startField: 2, endField: 2, numeric: true}, {startField: 1, endField: 1}}
+	r := rand.New(rand.NewSource(3))
+	text := make([]string, *benchLines)
+	for i := range text {
+		text[i] = fmt.Sprintf("%x\t%d", r.Int63(), r.Intn(1000))
+	}
+
+	for _, stable := range []bool{false, true} {
+		opts := SortOptions{keys: keys, stable: stable, parallel: workers}
+		opts.resolveKeys()
+		lines := make([]Line, len(text))
+		for i, line := range text {
+			lines[i] = makeLine(line, opts)
+		}
+		work := make([]Line, len(lines))
+
+		b.Run(fmt.Sprintf("stable=%v", stable), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				copy(work, lines)
+				sortLines(work, opts)
+			}
+		})
+	}
+}
+
+// BenchmarkSortSerial — однопоточная сортировка, с которой сравнивается
+// BenchmarkSortParallel. Размер входа задаётся флагом:
+// go test -bench 'Sort(Serial|Parallel)' -bench-lines=10000000
+func BenchmarkSortSerial(b *testing.B) {
+	benchSort(b, 1)
+}
+
+// BenchmarkSortParallel сортирует тот же вход в нескольких потоках.
+// --parallel по умолчанию равен 1, пока этот бенчмарк на многоядерной
+// машине не покажет ускорение относительно BenchmarkSortSerial.
+func BenchmarkSortParallel(b *testing.B) {
+	for _, workers := range []int{2, 4, 8} {
+		b.Run(fmt.Sprintf("parallel-%d", workers), func(b *testing.B) {
+			benchSort(b, workers)
+		})
+	}
+}
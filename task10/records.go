@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// namedKeyList — значение флага --key NAME[:OPTS]: путь JSON вида .user.age
+// при --jsonl или имя столбца CSV при --csv. Ключи добавляются в тот же
+// список, что и -k, поэтому порядок приоритета задаётся порядком флагов.
+type namedKeyList struct {
+	keys *keyList
+}
+
+func (l namedKeyList) String() string {
+	if l.keys == nil {
+		return ""
+	}
+	return l.keys.String()
+}
+
+func (l namedKeyList) Set(s string) error {
+	k, err := parseNamedKey(s)
+	if err != nil {
+		return err
+	}
+	*l.keys = append(*l.keys, k)
+	return nil
+}
+
+// parseNamedKey разбирает описание ключа по имени, например ".user.age:n"
+// или "salary:nr".
+func parseNamedKey(s string) (keySpec, error) {
+	name, mods, _ := strings.Cut(s, ":")
+	if name == "" || name == "." || strings.Contains(strings.TrimPrefix(name, "."), "..") {
+		return keySpec{}, fmt.Errorf("invalid key %q: empty name", s)
+	}
+	k := keySpec{name: name, startField: 1}
+	if err := parseModifiers(mods, &k); err != nil {
+		return keySpec{}, fmt.Errorf("invalid key %q: %v", s, err)
+	}
+	return k, nil
+}
+
+// hasNamedKeys сообщает, есть ли среди ключей заданные через --key.
+func (opts SortOptions) hasNamedKeys() bool {
+	for _, k := range opts.keys {
+		if k.name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonRange возвращает байтовые границы значения по пути path в JSON-объекте
+// line; у строк — без кавычек. Если значения нет или оно null, возвращает
+// пустой диапазон в конце строки, как для отсутствующего поля.
+func jsonRange(line, path string) (start, end int) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	path = strings.TrimPrefix(path, ".")
+	for {
+		name, rest, nested := strings.Cut(path, ".")
+		if !findMember(dec, name) {
+			return len(line), len(line)
+		}
+		if !nested {
+			break
+		}
+		path = rest
+	}
+
+	// Decoder останавливается после имени члена, до двоеточия
+	start = int(dec.InputOffset())
+	for start < len(line) && strings.IndexByte(": \t\r\n", line[start]) >= 0 {
+		start++
+	}
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return len(line), len(line)
+	}
+	end = int(dec.InputOffset())
+	if string(raw) == "null" {
+		return len(line), len(line)
+	}
+	if raw[0] == '"' {
+		return start + 1, end - 1
+	}
+	return start, end
+}
+
+// findMember читает начало объекта до члена name и останавливается перед
+// его значением; остальные значения пропускаются.
+func findMember(dec *json.Decoder, name string) bool {
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if tok == name {
+			return true
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// unquoteJSON раскрывает escape-последовательности строкового значения
+// line[start:end], если оно стоит в кавычках; иначе возвращает его как есть.
+func unquoteJSON(line string, start, end int) string {
+	if start > 0 && end < len(line) && line[start-1] == '"' && line[end] == '"' {
+		var s string
+		if json.Unmarshal([]byte(line[start-1:end+1]), &s) == nil {
+			return s
+		}
+	}
+	return line[start:end]
+}
+
+// readHeaders читает первую строку каждого входа как заголовок CSV и по
+// заголовку первого входа заменяет именованные ключи номерами столбцов.
+// Возвращает входы, из которых заголовок уже прочитан, и сам заголовок
+// с переводом строки; если первый вход пуст — пустую строку.
+func readHeaders(inputs []io.Reader, opts *SortOptions) (string, []io.Reader, error) {
+	var header string
+	empty := false
+	rest := make([]io.Reader, len(inputs))
+	for i, r := range inputs {
+		in := bufio.NewReader(r)
+		line, err := readLine(in)
+		if err != nil && err != io.EOF {
+			return "", nil, err
+		}
+		if i == 0 {
+			header, empty = line, err == io.EOF
+		}
+		rest[i] = in
+	}
+	if empty {
+		return "", rest, nil
+	}
+
+	keys := make([]keySpec, len(opts.keys))
+	copy(keys, opts.keys)
+	bounds := splitFields(header, *opts)
+	for i, k := range keys {
+		if k.name == "" {
+			continue
+		}
+		column := -1
+		for j, b := range bounds {
+			if header[b[0]:b[1]] == k.name {
+				column = j
+				break
+			}
+		}
+		if column < 0 {
+			return "", nil, fmt.Errorf("column %q not found in header", k.name)
+		}
+		keys[i].name = ""
+		keys[i].startField, keys[i].endField = column+1, column+1
+	}
+	opts.keys = keys
+	return header + "\n", rest, nil
+}
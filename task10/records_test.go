@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestJSONKey тестирует извлечение ключа по пути JSON
+func TestJSONKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		path     string
+		expected string
+	}{
+		{name: "число", line: `{"age": 42, "name": "ann"}`, path: ".age", expected: "42"},
+		{name: "строка без кавычек", line: `{"age": 42, "name": "ann"}`, path: ".name", expected: "ann"},
+		{name: "вложенный объект", line: `{"user":{"tags":[1,{"a":2}],"age":7}}`, path: ".user.age", expected: "7"},
+		{name: "escape-последовательности", line: `{"name":"а\"b"}`, path: ".name", expected: `а"b`},
+		{name: "массив как значение", line: `{"a":[1, 2]}`, path: ".a", expected: "[1, 2]"},
+		{name: "нет члена", line: `{"user":{"name":"ann"}}`, path: ".user.age", expected: ""},
+		{name: "не объект", line: `{"user":5}`, path: ".user.age", expected: ""},
+		{name: "null", line: `{"age":null}`, path: ".age", expected: ""},
+		{name: "не JSON", line: `age=5`, path: ".age", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := extractKey(test.line, nil, keySpec{name: test.path})
+			if result != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, result)
+			}
+		})
+	}
+}
+
+// TestParseNamedKey тестирует разбор ключа --key
+func TestParseNamedKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected keySpec
+		wantErr  bool
+	}{
+		{input: ".user.age:n", expected: keySpec{name: ".user.age", startField: 1, numeric: true}},
+		{input: "salary:nr", expected: keySpec{name: "salary", startField: 1, numeric: true, reverse: true}},
+		{input: "dept", expected: keySpec{name: "dept", startField: 1}},
+		{input: ":n", wantErr: true},
+		{input: ".a..b", wantErr: true},
+		{input: "salary:x", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, err := parseNamedKey(test.input)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ожидалась ошибка, получено %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("ожидалось %+v, получено %+v", test.expected, result)
+			}
+		})
+	}
+}
+
+// TestKeyFlagsUsage проверяет, что в справке у -k и --key нет значения по умолчанию
+func TestKeyFlagsUsage(t *testing.T) {
+	var keys keyList
+	fs := flag.NewFlagSet("sort", flag.ContinueOnError)
+	fs.Var(&keys, "k", "ключ сортировки")
+	fs.Var(namedKeyList{&keys}, "key", "ключ по имени")
+	var usage bytes.Buffer
+	fs.SetOutput(&usage)
+	fs.PrintDefaults()
+	if strings.Contains(usage.String(), "default") {
+		t.Errorf("ожидалась справка без значений по умолчанию, получено %q", usage.String())
+	}
+}
+
+// TestSortRecords тестирует сортировку JSON Lines и CSV с заголовком
+func TestSortRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []string
+		opts     SortOptions
+		expected string
+	}{
+		{
+			name:     "JSON Lines по вложенному полю",
+			inputs:   []string{"{\"user\":{\"age\":30}}\n{\"user\":{\"age\":4}}\n{\"id\":1}\n"},
+			opts:     SortOptions{jsonl: true, keys: []keySpec{{name: ".user.age", startField: 1, numeric: true}}},
+			expected: "{\"id\":1}\n{\"user\":{\"age\":4}}\n{\"user\":{\"age\":30}}\n",
+		},
+		{
+			name:     "CSV по имени столбца",
+			inputs:   []string{"name,salary\nann,100\n\"Doe, J\",300\nbob,20\n"},
+			opts:     SortOptions{csv: true, keys: []keySpec{{name: "salary", startField: 1, numeric: true, reverse: true}}},
+			expected: "name,salary\n\"Doe, J\",300\nann,100\nbob,20\n",
+		},
+		{
+			name: "CSV из нескольких файлов",
+			inputs: []string{
+				"dept,name\nops,bob\n",
+				"dept,name\ndev,ann\n",
+			},
+			opts:     SortOptions{csv: true, keys: []keySpec{{name: "dept", startField: 1}}},
+			expected: "dept,name\ndev,ann\nops,bob\n",
+		},
+		{
+			name:     "пустой CSV",
+			inputs:   []string{""},
+			opts:     SortOptions{csv: true, keys: []keySpec{{name: "dept", startField: 1}}},
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var inputs []io.Reader
+			for _, input := range test.inputs {
+				inputs = append(inputs, strings.NewReader(input))
+			}
+			opts := test.opts
+			var out bytes.Buffer
+			if opts.csv {
+				header, rest, err := readHeaders(inputs, &opts)
+				if err != nil {
+					t.Fatalf("ожидалось nil, получено: %v", err)
+				}
+				out.WriteString(header)
+				inputs = rest
+			}
+			if err := sortReaders(inputs, &out, opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if out.String() != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+// TestReadHeadersMissingColumn проверяет ошибку для неизвестного столбца
+func TestReadHeadersMissingColumn(t *testing.T) {
+	opts := SortOptions{csv: true, keys: []keySpec{{name: "age", startField: 1}}}
+	_, _, err := readHeaders([]io.Reader{strings.NewReader("name,salary\nann,1\n")}, &opts)
+	if err == nil || err.Error() != `column "age" not found in header` {
+		t.Errorf("ожидалась ошибка о столбце age, получено: %v", err)
+	}
+}
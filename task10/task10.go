@@ -1,32 +1,66 @@
 package main
 
 import (
-	"bufio"
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"os"
-	"sort"
-	"strconv"
 	"strings"
+	"unsafe"
+
+	"golang.org/x/text/collate"
 )
 
 // SortOptions содержит все опции сортировки
 type SortOptions struct {
-	keyColumn    int  // -k N
-	numeric      bool // -n
-	reverse      bool // -r
-	unique       bool // -u
-	monthSort    bool // -M
-	ignoreBlanks bool // -b
-	checkSorted  bool // -c
-	humanNumeric bool // -h
+	keys         []keySpec // -k POS1[,POS2], в порядке приоритета
+	resolved     []keySpec // ключи с унаследованными глобальными опциями, см. resolveKeys
+	numeric      bool      // -n
+	reverse      bool      // -r
+	unique       bool      // -u
+	monthSort    bool      // -M
+	ignoreBlanks bool      // -b
+	checkSorted  bool      // -c
+	humanNumeric bool      // -h
+	foldCase     bool      // -f
+	dictionary   bool      // -d
+	general      bool      // -g
+	version      bool      // -V
+	random       bool      // -R: перемешать, группируя равные ключи
+	seed         uint64    // --seed, зерно хеша для -R
+	stable       bool      // -s: не сравнивать строки целиком при равных ключах
+	debug        bool      // --debug: подчёркивать ключи в выводе
+	separator    rune      // -t, 0 — поля разделяются последовательностями пробелов
+	csv          bool      // -csv: поля в кавычках не делятся разделителем
+	jsonl        bool      // --jsonl: строки — объекты JSON, --key задаёт путь
+
+	bufferSize int64  // -S, 0 — без ограничения
+	tempDir    string // -T
+	parallel   int    // --parallel, число потоков сортировки
+	head       int    // --head N: только N первых строк, 0 — все
+	tail       int    // --tail N: только N последних строк, 0 — все
+
+	collator *collate.Collator // --locale, nil — побайтное сравнение
+	numbers  numberFormat      // --locale, разделители в числах
 }
 
 // Line представляет строку для сортировки с дополнительными данными
 type Line struct {
 	original string
-	key      string
-	value    interface{}
+	values   []keyValue // значения ключей в порядке opts.keys
+	collated string     // ключ всей строки по правилам --locale для последнего сравнения
+}
+
+// keyValue — значение ключа, разобранное parseValue; заполнены только поля
+// его типа, поэтому сравнение обходится без приведения типов.
+type keyValue struct {
+	text    string        // строковый ключ, а также ключ -V и -R
+	number  *numericValue // -n и -h
+	general generalValue  // -g
+	order   uint64        // номер месяца при -M, хеш при -R
 }
 
 // MonthMap содержит соответствие названий месяцев и их номеров
@@ -35,295 +69,325 @@ var monthMap = map[string]int{
 	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
 }
 
-// parseHumanNumeric парсит человекочитаемые размеры (K, M, G)
-func parseHumanNumeric(s string) (float64, error) {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return 0, fmt.Errorf("empty string")
+// parseMonth разбирает название месяца по первым трём буквам после пробелов,
+// как GNU sort -M: "Jan", "JANUARY" и " jan." — январь. Неизвестное название
+// даёт 0 и идёт раньше января. Возвращает также границы разобранной части.
+func parseMonth(s string) (month, start, end int) {
+	for start < len(s) && isBlank(s[start]) {
+		start++
 	}
-
-	s = strings.ToLower(strings.ReplaceAll(s, " ", ""))
-
-	var multiplier float64 = 1
-	var suffix string
-
-	if strings.HasSuffix(s, "k") {
-		multiplier = 1024
-		suffix = "k"
-	} else if strings.HasSuffix(s, "m") {
-		multiplier = 1024 * 1024
-		suffix = "m"
-	} else if strings.HasSuffix(s, "g") {
-		multiplier = 1024 * 1024 * 1024
-		suffix = "g"
-	} else if strings.HasSuffix(s, "t") {
-		multiplier = 1024 * 1024 * 1024 * 1024
-		suffix = "t"
-	}
-
-	if suffix != "" {
-		s = strings.TrimSuffix(s, suffix)
-	}
-
-	value, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return 0, err
+	if len(s)-start >= 3 {
+		if month, ok := monthMap[strings.ToLower(s[start:start+3])]; ok {
+			return month, start, start + 3
+		}
 	}
-
-	return value * multiplier, nil
+	return 0, start, start
 }
 
-// parseMonth парсит название месяца
-func parseMonth(s string) (int, error) {
-	s = strings.TrimSpace(strings.ToLower(s))
-	if month, exists := monthMap[s]; exists {
-		return month, nil
+// parseValue разбирает ключ согласно его типу. Как в GNU sort, ключ без
+// числа равен нулю при -n и -h и идёт раньше всех чисел при -g, а
+// --debug помечает такой ключ "no match for key".
+func parseValue(key string, k keySpec, opts SortOptions) keyValue {
+	switch k.kind() {
+	case numericKey, humanKey:
+		value, _, _ := parseNumeric(key, opts.numbers, k.kind() == humanKey)
+		return keyValue{number: &value}
+	case generalKey:
+		return keyValue{general: parseGeneral(key, opts.numbers)}
+	case monthKey:
+		month, _, _ := parseMonth(key)
+		return keyValue{order: uint64(month)}
+	case versionKey:
+		return keyValue{text: key}
 	}
-	return 0, fmt.Errorf("invalid month: %s", s)
-}
 
-// extractKey извлекает ключ для сортировки из строки
-func extractKey(line string, opts SortOptions) string {
-	if opts.keyColumn <= 0 {
-		return line
+	if k.dictionary {
+		key = dictionaryOrder(key)
 	}
-
-	// Разделяем по табуляции
-	fields := strings.Split(line, "\t")
-	if opts.keyColumn > len(fields) {
-		return ""
+	if k.foldCase {
+		key = strings.ToUpper(key)
 	}
-
-	key := fields[opts.keyColumn-1]
-	if opts.ignoreBlanks {
-		key = strings.TrimSpace(key)
+	if k.kind() == randomKey {
+		return keyValue{text: key, order: randomHash(opts.seed, key)}
 	}
-
-	return key
+	return keyValue{text: key}
 }
 
-// parseValue парсит значение в зависимости от типа сортировки
-func parseValue(key string, opts SortOptions) interface{} {
-	if opts.numeric {
-		if val, err := strconv.ParseFloat(key, 64); err == nil {
-			return val
+// makeLine разбирает строку для сортировки по ключам opts.resolved
+func makeLine(line string, opts SortOptions) Line {
+	bounds := splitFields(line, opts)
+	values := make([]keyValue, len(opts.resolved))
+	for i, k := range opts.resolved {
+		values[i] = parseValue(extractKey(line, bounds, k), k, opts)
+		if k.kind() == textKey && opts.collator != nil {
+			values[i].text = collationKey(opts.collator, values[i].text)
 		}
-		return 0.0
 	}
-
-	if opts.monthSort {
-		if val, err := parseMonth(key); err == nil {
-			return val
-		}
-		return 0
+	result := Line{original: line, values: values}
+	if opts.collator != nil && opts.lastResort() {
+		result.collated = collationKey(opts.collator, line)
 	}
+	return result
+}
 
-	if opts.humanNumeric {
-		if val, err := parseHumanNumeric(key); err == nil {
-			return val
+// size оценивает память под строку в части входа: сама Line, текст, ключ
+// --locale и разобранные значения ключей. Текст ключей считается отдельно,
+// даже если он — подстрока исходной, поэтому оценка не бывает меньше
+// настоящего расхода.
+func (l Line) size() int64 {
+	n := int64(unsafe.Sizeof(l)) + int64(len(l.original)+len(l.collated))
+	for _, v := range l.values {
+		n += int64(unsafe.Sizeof(v)) + int64(len(v.text))
+		if v.number != nil {
+			n += int64(unsafe.Sizeof(*v.number)) + int64(len(v.number.integer)+len(v.number.fraction))
 		}
-		return 0.0
 	}
-
-	return key
+	return n
 }
 
-// isSorted проверяет, отсортированы ли данные
-func isSorted(lines []Line, opts SortOptions) bool {
-	for i := 1; i < len(lines); i++ {
-		if compareLines(lines[i-1], lines[i], opts) > 0 {
-			return false
-		}
-	}
-	return true
+// lastResort сообщает, сравниваются ли строки целиком при равных ключах.
+// Как в GNU sort, -s и -u отключают это сравнение.
+func (opts SortOptions) lastResort() bool {
+	return !opts.stable && !opts.unique
 }
 
-// compareLines сравнивает две строки для сортировки
+// compareLines сравнивает две строки по ключам в порядке приоритета,
+// а при равных ключах — целиком
 func compareLines(a, b Line, opts SortOptions) int {
-	var result int
-
-	switch aVal := a.value.(type) {
-	case float64:
-		bVal := b.value.(float64)
-		if aVal < bVal {
-			result = -1
-		} else if aVal > bVal {
-			result = 1
-		} else {
-			result = 0
-		}
-	case int:
-		bVal := b.value.(int)
-		if aVal < bVal {
-			result = -1
-		} else if aVal > bVal {
-			result = 1
-		} else {
-			result = 0
+	if result := compareKeys(a, b, opts); result != 0 || !opts.lastResort() {
+		return result
+	}
+	result := strings.Compare(a.original, b.original)
+	if opts.collator != nil {
+		if result = strings.Compare(a.collated, b.collated); result == 0 {
+			result = strings.Compare(a.original, b.original)
 		}
-	case string:
-		bVal := b.value.(string)
-		result = strings.Compare(aVal, bVal)
 	}
-
 	if opts.reverse {
 		result = -result
 	}
-
-	return result
-}
-
-// removeDuplicates удаляет дубликаты из отсортированного списка
-func removeDuplicates(lines []Line, opts SortOptions) []Line {
-	if len(lines) == 0 {
-		return lines
-	}
-
-	result := make([]Line, 0, len(lines))
-	result = append(result, lines[0])
-
-	for i := 1; i < len(lines); i++ {
-		if compareLines(lines[i-1], lines[i], opts) != 0 {
-			result = append(result, lines[i])
-		}
-	}
 	return result
 }
 
-// readLines читает строки из файла или STDIN
-func readLines(filename string) ([]string, error) {
-	var file *os.File
-	var err error
-	var fileSize int64
-
-	if filename == "" || filename == "-" {
-		file = os.Stdin
-		fileSize = 0 // Неизвестный размер для STDIN
-	} else {
-		file, err = os.Open(filename)
-		if err != nil {
-			return nil, err
+// compareKeys сравнивает две строки только по ключам
+func compareKeys(a, b Line, opts SortOptions) int {
+	for i, k := range opts.resolved {
+		result := compareValues(k.kind(), &a.values[i], &b.values[i])
+		if k.reverse {
+			result = -result
 		}
-		defer file.Close()
-
-		// Получаем размер файла
-		fileInfo, err := file.Stat()
-		if err != nil {
-			return nil, err
+		if result != 0 {
+			return result
 		}
-		fileSize = fileInfo.Size()
 	}
+	return 0
+}
 
-	scanner := bufio.NewScanner(file)
-
-	var maxCapacity int
-	var initialCapacity int
-
-	if fileSize == 0 {
-		// STDIN или неизвестный размер - используем средние значения
-		maxCapacity = 64 * 1024 // 64KB
-		initialCapacity = 1000
-	} else if fileSize < 1024*1024 {
-		// Маленький файл (< 1MB)
-		maxCapacity = 32 * 1024 // 32KB
-		initialCapacity = 100
-	} else if fileSize < 100*1024*1024 {
-		// Средний файл (1MB - 100MB)
-		maxCapacity = 64 * 1024 // 64KB
-		initialCapacity = 10000
-	} else {
-		// Большой файл (> 100MB)
-		maxCapacity = 1024 * 1024 // 1MB
-		initialCapacity = 100000
-	}
-
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
-
-	var lines []string
-	lines = make([]string, 0, initialCapacity)
-
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+// compareValues сравнивает значения одного ключа, разобранные parseValue
+func compareValues(kind keyType, a, b *keyValue) int {
+	switch kind {
+	case numericKey:
+		return compareNumeric(a.number, b.number)
+	case humanKey:
+		return compareHuman(a.number, b.number)
+	case generalKey:
+		return compareGeneral(a.general, b.general)
+	case monthKey:
+		return cmp.Compare(a.order, b.order)
+	case versionKey:
+		return compareVersions(a.text, b.text)
+	case randomKey:
+		return compareRandom(a, b)
 	}
-
-	return lines, scanner.Err()
+	return strings.Compare(a.text, b.text)
 }
 
 func main() {
 	// Определяем флаги
+	var keys keyList
+	flag.Var(&keys, "k", "ключ сортировки POS1[,POS2][OPTS], например 2,2n или 3.2,3.5; можно указать несколько раз")
+	flag.Var(namedKeyList{&keys}, "key", "ключ по имени NAME[:OPTS]: путь JSON (.user.age:n) при --jsonl или столбец CSV (salary:nr) при --csv")
 	var (
-		keyColumn    = flag.Int("k", 0, "сортировать по столбцу N")
 		numeric      = flag.Bool("n", false, "сортировать по числовому значению")
 		reverse      = flag.Bool("r", false, "сортировать в обратном порядке")
 		unique       = flag.Bool("u", false, "не выводить повторяющиеся строки")
 		monthSort    = flag.Bool("M", false, "сортировать по названию месяца")
 		ignoreBlanks = flag.Bool("b", false, "игнорировать хвостовые пробелы")
-		checkSorted  = flag.Bool("c", false, "проверить, отсортированы ли данные")
-		humanNumeric = flag.Bool("h", false, "сортировать по человекочитаемым размерам")
+		checkSorted  = flag.Bool("c", false, "проверить порядок и сообщить о первом нарушении (код выхода 1)")
+		checkQuiet   = flag.Bool("C", false, "как -c, но без сообщения")
+		humanNumeric = flag.Bool("h", false, "сортировать по человекочитаемым размерам: 1K = 1000, 1Ki = 1024")
+		foldCase     = flag.Bool("f", false, "не различать регистр букв")
+		dictionary   = flag.Bool("d", false, "учитывать только пробелы, буквы и цифры")
+		general      = flag.Bool("g", false, "сортировать по общему числовому значению (экспонента, inf, nan)")
+		version      = flag.Bool("V", false, "сортировать как номера версий")
+		random       = flag.Bool("R", false, "перемешать строки случайно, равные ключи остаются рядом")
+		seed         = flag.Uint64("seed", 0, "зерно хеша для -R, 0 — случайное")
+		stable       = flag.Bool("s", false, "стабильная сортировка: строки с равными ключами сохраняют порядок")
+		debug        = flag.Bool("debug", false, "подчёркивать часть строки, использованную как ключ")
+		locale       = flag.String("locale", "", "правила сравнения строк Unicode для локали, например ru или en")
+		bufferSize   = flag.String("S", "", "размер буфера в памяти, например 512M (без суффикса — килобайты)")
+		tempDir      = flag.String("T", "", "каталог для временных файлов")
+		separator    = flag.String("t", "", "разделитель полей (по умолчанию — последовательности пробелов и табуляций)")
+		parallel     = flag.Int("parallel", 1, "число потоков сортировки")
+		head         = flag.Int("head", 0, "вывести только N первых строк, не сортируя весь вход")
+		tail         = flag.Int("tail", 0, "вывести только N последних строк, не сортируя весь вход")
+		csvFields    = flag.Bool("csv", false, "разбирать поля как CSV: кавычки защищают разделитель (по умолчанию ','); с --key первая строка — заголовок")
+		jsonl        = flag.Bool("jsonl", false, "строки — объекты JSON (JSON Lines), ключи задаются --key")
+		merge        = flag.Bool("m", false, "слить уже отсортированные файлы, не сортируя их заново")
+		output       = flag.String("o", "", "записать результат в файл (может совпадать с входным)")
 	)
 
 	flag.Parse()
 
+	size, err := parseBufferSize(*bufferSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Неверный размер буфера: %v\n", err)
+		os.Exit(2)
+	}
+
+	sep, err := parseSeparator(*separator)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Неверный разделитель: %v\n", err)
+		os.Exit(2)
+	}
+
+	collator, err := newCollator(*locale)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Неверная локаль: %v\n", err)
+		os.Exit(2)
+	}
+	numbers, _ := localeNumbers(*locale)
+
 	// Создаём опции сортировки
 	opts := SortOptions{
-		keyColumn:    *keyColumn,
+		keys:         keys,
 		numeric:      *numeric,
 		reverse:      *reverse,
 		unique:       *unique,
 		monthSort:    *monthSort,
 		ignoreBlanks: *ignoreBlanks,
-		checkSorted:  *checkSorted,
+		checkSorted:  *checkSorted || *checkQuiet,
 		humanNumeric: *humanNumeric,
+		foldCase:     *foldCase,
+		dictionary:   *dictionary,
+		general:      *general,
+		version:      *version,
+		random:       *random,
+		seed:         *seed,
+		stable:       *stable,
+		debug:        *debug,
+		separator:    sep,
+		csv:          *csvFields,
+		jsonl:        *jsonl,
+		bufferSize:   size,
+		tempDir:      *tempDir,
+		parallel:     *parallel,
+		head:         *head,
+		tail:         *tail,
+		collator:     collator,
+		numbers:      numbers,
+	}
+	if err := opts.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Неверные опции: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.seed == 0 {
+		opts.seed = rand.Uint64()
 	}
 
-	// Получаем имя файла из аргументов
-	var filename string
-	if len(flag.Args()) > 0 {
-		filename = flag.Args()[0]
+	// Проверяем порядок: 0 — отсортировано, 1 — нарушение, 2 — ошибка
+	if opts.checkSorted {
+		if flag.NArg() > 1 {
+			fmt.Fprintf(os.Stderr, "Лишний операнд %q: -c проверяет один файл\n", flag.Arg(1))
+			os.Exit(2)
+		}
+		name := "-"
+		if flag.NArg() == 1 {
+			name = flag.Arg(0)
+		}
+		inputs, closeInputs, err := openInputs([]string{name})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка чтения файла: %v\n", err)
+			os.Exit(2)
+		}
+		input, header := inputs[0], ""
+		if opts.csv && opts.hasNamedKeys() {
+			header, inputs, err = readHeaders(inputs, &opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Ошибка чтения заголовка: %v\n", err)
+				os.Exit(2)
+			}
+			input = inputs[0]
+		}
+		err = checkStream(input, name, opts)
+		closeInputs()
+		var disorder *disorderError
+		if errors.As(err, &disorder) {
+			if header != "" {
+				disorder.line++ // нумерация строк файла учитывает заголовок
+			}
+			if !*checkQuiet {
+				fmt.Fprintln(os.Stderr, disorder)
+			}
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка чтения файла: %v\n", err)
+			os.Exit(2)
+		}
+		return
 	}
 
-	// Читаем строки
-	lines, err := readLines(filename)
+	inputs, closeInputs, err := openInputs(flag.Args())
 	if err != nil {
-		fmt.Printf("Ошибка чтения файла: %v", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Ошибка чтения файла: %v\n", err)
+		os.Exit(2)
 	}
+	defer closeInputs()
 
-	sortLines := make([]Line, 0, len(lines))
-	// Преобразуем строки в структуры для сортировки
-	for _, line := range lines {
-		key := extractKey(line, opts)
-		value := parseValue(key, opts)
-		sortLines = append(sortLines, Line{
-			original: line,
-			key:      key,
-			value:    value,
-		})
+	// Заголовок CSV остаётся первой строкой вывода
+	var header string
+	if opts.csv && opts.hasNamedKeys() {
+		header, inputs, err = readHeaders(inputs, &opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка чтения заголовка: %v\n", err)
+			os.Exit(2)
+		}
 	}
 
-	// Проверяем, отсортированы ли данные
-	if opts.checkSorted {
-		if isSorted(sortLines, opts) {
-			fmt.Println("Данные отсортированы")
-		} else {
-			fmt.Println("Данные не отсортированы")
+	var out io.Writer = os.Stdout
+	var outFile *outputFile
+	if *output != "" {
+		outFile, err = createOutput(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка открытия файла вывода: %v\n", err)
+			os.Exit(2)
 		}
-		return
+		out = outFile
 	}
 
-	// Сортируем строки
-	sort.Slice(sortLines, func(i, j int) bool {
-		return compareLines(sortLines[i], sortLines[j], opts) < 0
-	})
-
-	// Удаляем дубликаты если нужно
-	if opts.unique {
-		sortLines = removeDuplicates(sortLines, opts)
+	// Выбираем первые строки, сливаем готовые файлы или сортируем; при нехватке
+	// буфера — через временные файлы
+	_, err = io.WriteString(out, header)
+	switch {
+	case err != nil:
+	case opts.head > 0 || opts.tail > 0:
+		err = selectLines(inputs, out, opts)
+	case *merge:
+		err = mergeStreams(inputs, out, opts)
+	default:
+		err = sortReaders(inputs, out, opts)
 	}
-
-	// Выводим результат сортировки
-	for _, line := range sortLines {
-		fmt.Println(line.original)
+	if outFile != nil {
+		if err != nil {
+			outFile.Abort()
+		} else {
+			err = outFile.Commit()
+		}
+	}
+	if err != nil {
+		closeInputs()
+		fmt.Fprintf(os.Stderr, "Ошибка сортировки: %v\n", err)
+		os.Exit(1)
 	}
 }
@@ -1,8 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"reflect"
-	"sort"
+	"strings"
 	"testing"
 )
 
@@ -11,38 +12,69 @@ func TestExtractKey(t *testing.T) {
 	tests := []struct {
 		name     string
 		line     string
-		opts     SortOptions
+		key      keySpec
 		expected string
 	}{
 		{
 			name:     "без указания столбца",
 			line:     "apple\t5\tJan",
-			opts:     SortOptions{keyColumn: 0},
+			key:      keySpec{startField: 1},
 			expected: "apple\t5\tJan",
 		},
 		{
 			name:     "первый столбец",
 			line:     "apple\t5\tJan",
-			opts:     SortOptions{keyColumn: 1},
+			key:      keySpec{startField: 1, endField: 1},
 			expected: "apple",
 		},
 		{
 			name:     "столбец с пробелами",
 			line:     "apple\t 5 \tJan",
-			opts:     SortOptions{keyColumn: 2, ignoreBlanks: true},
+			key:      keySpec{startField: 2, endField: 2, ignoreBlanks: true},
 			expected: "5",
 		},
 		{
 			name:     "несуществующий столбец",
 			line:     "apple\t5",
-			opts:     SortOptions{keyColumn: 5},
+			key:      keySpec{startField: 5, endField: 5},
+			expected: "",
+		},
+		{
+			name:     "от столбца до конца строки",
+			line:     "apple\t5\tJan",
+			key:      keySpec{startField: 2},
+			expected: "5\tJan",
+		},
+		{
+			name:     "символы внутри поля",
+			line:     "a\tb\tabcdefg",
+			key:      keySpec{startField: 3, startChar: 2, endField: 3, endChar: 5},
+			expected: "bcde",
+		},
+		{
+			name:     "символы кириллицы",
+			line:     "x\tпривет",
+			key:      keySpec{startField: 2, startChar: 3, endField: 2, endChar: 4},
+			expected: "ив",
+		},
+		{
+			name:     "смещение за концом поля",
+			line:     "ab\tcd",
+			key:      keySpec{startField: 1, startChar: 5, endField: 1},
 			expected: "",
 		},
+		{
+			name:     "несколько полей",
+			line:     "a\tb\tc\td",
+			key:      keySpec{startField: 2, endField: 3},
+			expected: "b\tc",
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result := extractKey(test.line, test.opts)
+			bounds := splitFields(test.line, SortOptions{separator: '\t'})
+			result := extractKey(test.line, bounds, test.key)
 			if result != test.expected {
 				t.Errorf("ожидалось %q, получено %q", test.expected, result)
 			}
@@ -50,55 +82,138 @@ func TestExtractKey(t *testing.T) {
 	}
 }
 
+// TestSplitFields тестирует разбиение строки на поля
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		opts     SortOptions
+		expected []string
+	}{
+		{
+			name:     "последовательности пробелов",
+			line:     "  apple   5\tJan",
+			opts:     SortOptions{},
+			expected: []string{"  apple", "   5", "\tJan"},
+		},
+		{
+			name:     "пустая строка",
+			line:     "",
+			opts:     SortOptions{},
+			expected: []string{""},
+		},
+		{
+			name:     "разделитель",
+			line:     "a:b::c",
+			opts:     SortOptions{separator: ':'},
+			expected: []string{"a", "b", "", "c"},
+		},
+		{
+			name:     "разделитель не ASCII",
+			line:     "а│б",
+			opts:     SortOptions{separator: '│'},
+			expected: []string{"а", "б"},
+		},
+		{
+			name:     "CSV с кавычками",
+			line:     `1,"Smith, John",42`,
+			opts:     SortOptions{csv: true},
+			expected: []string{"1", "Smith, John", "42"},
+		},
+		{
+			name:     "CSV с удвоенной кавычкой",
+			line:     `"say ""hi"", ok";x`,
+			opts:     SortOptions{csv: true, separator: ';'},
+			expected: []string{`say ""hi"", ok`, "x"},
+		},
+		{
+			name:     "CSV с пустыми полями",
+			line:     `,"",`,
+			opts:     SortOptions{csv: true},
+			expected: []string{"", "", ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var result []string
+			for _, b := range splitFields(test.line, test.opts) {
+				result = append(result, test.line[b[0]:b[1]])
+			}
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("ожидалось %q, получено %q", test.expected, result)
+			}
+		})
+	}
+}
+
 // TestParseValue тестирует функцию парсинга значений
 func TestParseValue(t *testing.T) {
 	tests := []struct {
 		name     string
 		key      string
-		opts     SortOptions
-		expected interface{}
+		spec     keySpec
+		expected keyValue
 	}{
 		{
 			name:     "обычная строка",
 			key:      "apple",
-			opts:     SortOptions{},
-			expected: "apple",
+			spec:     keySpec{},
+			expected: keyValue{text: "apple"},
 		},
 		{
 			name:     "числовое значение",
 			key:      "123",
-			opts:     SortOptions{numeric: true},
-			expected: 123.0,
+			spec:     keySpec{numeric: true},
+			expected: keyValue{number: &numericValue{integer: "123"}},
 		},
 		{
-			name:     "нечисловое значение при числовой сортировке",
-			key:      "abc",
-			opts:     SortOptions{numeric: true},
-			expected: 0.0,
+			name:     "нечисловое значение равно нулю",
+			key:      "N/A",
+			spec:     keySpec{numeric: true},
+			expected: keyValue{number: &numericValue{}},
+		},
+		{
+			name:     "число в начале ключа",
+			key:      " -012.50ms",
+			spec:     keySpec{numeric: true},
+			expected: keyValue{number: &numericValue{negative: true, integer: "12", fraction: "5"}},
 		},
 		{
 			name:     "месяц Dec",
 			key:      "Dec",
-			opts:     SortOptions{monthSort: true},
-			expected: 12,
+			spec:     keySpec{monthSort: true},
+			expected: keyValue{order: 12},
+		},
+		{
+			name:     "полное название месяца",
+			key:      " JANUARY",
+			spec:     keySpec{monthSort: true},
+			expected: keyValue{order: 1},
 		},
 		{
 			name:     "неверный месяц",
 			key:      "Invalid",
-			opts:     SortOptions{monthSort: true},
-			expected: 0,
+			spec:     keySpec{monthSort: true},
+			expected: keyValue{order: 0},
 		},
 		{
 			name:     "человекочитаемый размер 1K",
 			key:      "1K",
-			opts:     SortOptions{humanNumeric: true},
-			expected: 1024.0,
+			spec:     keySpec{humanNumeric: true},
+			expected: keyValue{number: &numericValue{integer: "1", scale: 1, magnitude: 1000}},
+		},
+		{
+			name:     "человекочитаемый размер 1Ki",
+			key:      "1Ki",
+			spec:     keySpec{humanNumeric: true},
+			expected: keyValue{number: &numericValue{integer: "1", scale: 1, binary: true, magnitude: 1024}},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result := parseValue(test.key, test.opts)
+			result := parseValue(test.key, test.spec, SortOptions{})
 			if !reflect.DeepEqual(result, test.expected) {
 				t.Errorf("ожидалось %v, получено %v", test.expected, result)
 			}
@@ -138,36 +253,109 @@ func TestSortLinesIntegration(t *testing.T) {
 			opts:     SortOptions{unique: true},
 			expected: []string{"apple", "banana", "cherry"},
 		},
+		{
+			name:  "по отделу, затем по зарплате по убыванию",
+			input: []string{"dev\tann\t100", "ops\tbob\t90", "dev\tcid\t250", "ops\tdan\t300"},
+			opts: SortOptions{keys: []keySpec{
+				{startField: 1, endField: 1},
+				{startField: 3, endField: 3, numeric: true, reverse: true},
+			}},
+			expected: []string{"dev\tcid\t250", "dev\tann\t100", "ops\tdan\t300", "ops\tbob\t90"},
+		},
+		{
+			name:     "выровненные пробелами столбцы",
+			input:    []string{"ann    300", "bob     20", "cid   1000"},
+			opts:     SortOptions{keys: []keySpec{{startField: 2, endField: 2, numeric: true, ignoreBlanks: true}}},
+			expected: []string{"bob     20", "ann    300", "cid   1000"},
+		},
+		{
+			name:     "CSV со значением в кавычках",
+			input:    []string{`"Smith, John",30`, `"Doe, Jane",25`, `Bond,40`},
+			opts:     SortOptions{csv: true, keys: []keySpec{{startField: 2, endField: 2, numeric: true}}},
+			expected: []string{`"Doe, Jane",25`, `"Smith, John",30`, `Bond,40`},
+		},
+		{
+			name:     "равные ключи сравниваются целиком",
+			input:    []string{"b\t1", "c\t1", "a\t1"},
+			opts:     SortOptions{keys: []keySpec{{startField: 2, endField: 2, numeric: true}}},
+			expected: []string{"a\t1", "b\t1", "c\t1"},
+		},
+		{
+			name:     "-r без модификатора ключа обращает только последнее сравнение",
+			input:    []string{"b\t1", "c\t1", "a\t2"},
+			opts:     SortOptions{reverse: true, keys: []keySpec{{startField: 2, endField: 2, numeric: true}}},
+			expected: []string{"c\t1", "b\t1", "a\t2"},
+		},
+		{
+			name:     "стабильная сортировка",
+			input:    []string{"b\t1", "c\t1", "a\t1"},
+			opts:     SortOptions{stable: true, keys: []keySpec{{startField: 2, endField: 2, numeric: true}}},
+			expected: []string{"b\t1", "c\t1", "a\t1"},
+		},
+		{
+			name:  "глобальные опции для ключей без модификаторов",
+			input: []string{"b\t2", "a\t10", "a\t9"},
+			opts: SortOptions{numeric: true, keys: []keySpec{
+				{startField: 2, endField: 2},
+				{startField: 1, endField: 1, reverse: true},
+			}},
+			expected: []string{"b\t2", "a\t9", "a\t10"},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			lines := make([]Line, len(test.input))
-			for i, line := range test.input {
-				key := extractKey(line, test.opts)
-				value := parseValue(key, test.opts)
-				lines[i] = Line{
-					original: line,
-					key:      key,
-					value:    value,
-				}
+			var out bytes.Buffer
+			input := strings.Join(test.input, "\n") + "\n"
+			if err := sortStream(strings.NewReader(input), &out, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
 			}
 
-			sort.Slice(lines, func(i, j int) bool {
-				return compareLines(lines[i], lines[j], test.opts) < 0
-			})
-
-			if test.opts.unique {
-				lines = removeDuplicates(lines, test.opts)
+			result := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("ожидалось %v, получено %v", test.expected, result)
 			}
+		})
+	}
+}
 
-			result := make([]string, len(lines))
-			for i, line := range lines {
-				result[i] = line.original
-			}
+// TestParseKeySpec тестирует разбор описания ключа
+func TestParseKeySpec(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected keySpec
+		wantErr  bool
+	}{
+		{input: "2", expected: keySpec{startField: 2}},
+		{input: "2,2n", expected: keySpec{startField: 2, endField: 2, numeric: true}},
+		{input: "1,1r", expected: keySpec{startField: 1, endField: 1, reverse: true}},
+		{input: "3.2,3.5", expected: keySpec{startField: 3, startChar: 2, endField: 3, endChar: 5}},
+		{input: "2b,2Mr", expected: keySpec{startField: 2, endField: 2, ignoreBlanks: true, monthSort: true, reverse: true}},
+		{input: "1.3h", expected: keySpec{startField: 1, startChar: 3, humanNumeric: true}},
+		{input: "2,2g", expected: keySpec{startField: 2, endField: 2, general: true}},
+		{input: "1V", expected: keySpec{startField: 1, version: true}},
+		{input: "2,2R", expected: keySpec{startField: 2, endField: 2, random: true}},
+		{input: "1,1fd", expected: keySpec{startField: 1, endField: 1, foldCase: true, dictionary: true}},
+		{input: "0,1", wantErr: true},
+		{input: "1.0", wantErr: true},
+		{input: "1,2x", wantErr: true},
+		{input: "", wantErr: true},
+	}
 
-			if !reflect.DeepEqual(result, test.expected) {
-				t.Errorf("ожидалось %v, получено %v", test.expected, result)
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, err := parseKeySpec(test.input)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ожидалась ошибка, получено %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("ожидалось %+v, получено %+v", test.expected, result)
 			}
 		})
 	}
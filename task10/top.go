@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"sort"
+)
+
+// rankedLine — строка с номером во входе, который разрешает равенство
+// ключей так же, как стабильная сортировка.
+type rankedLine struct {
+	line  Line
+	index int
+}
+
+// before сообщает, идёт ли a раньше b в отсортированном выводе.
+func before(a, b rankedLine, opts SortOptions) bool {
+	if c := compareLines(a.line, b.line, opts); c != 0 {
+		return c < 0
+	}
+	return a.index < b.index
+}
+
+// topHeap хранит не больше n отобранных строк. Для --head на вершине
+// самая поздняя в порядке сортировки строка, для --tail — самая ранняя:
+// её вытесняет следующая подходящая строка.
+type topHeap struct {
+	opts  SortOptions
+	tail  bool
+	items []rankedLine
+}
+
+func (h *topHeap) Len() int { return len(h.items) }
+
+func (h *topHeap) Less(i, j int) bool {
+	if h.tail {
+		return before(h.items[i], h.items[j], h.opts)
+	}
+	return before(h.items[j], h.items[i], h.opts)
+}
+
+func (h *topHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *topHeap) Push(x any) { h.items = append(h.items, x.(rankedLine)) }
+
+func (h *topHeap) Pop() any {
+	old := h.items
+	item := old[len(old)-1]
+	h.items = old[:len(old)-1]
+	return item
+}
+
+// offer добавляет строку, если она входит в n отобранных.
+func (h *topHeap) offer(x rankedLine, n int) {
+	if len(h.items) < n {
+		heap.Push(h, x)
+		return
+	}
+	top := h.items[0]
+	if h.tail && before(top, x, h.opts) || !h.tail && before(x, top, h.opts) {
+		h.items[0] = x
+		heap.Fix(h, 0)
+	}
+}
+
+// selectLines выводит opts.head первых или opts.tail последних строк
+// в порядке сортировки, как sort | head и sort | tail, но держит в памяти
+// только отобранные строки: O(m log n) для m строк входа.
+func selectLines(inputs []io.Reader, w io.Writer, opts SortOptions) error {
+	opts.resolveKeys()
+	n, tail := opts.head, false
+	if opts.tail > 0 {
+		n, tail = opts.tail, true
+	}
+	h := &topHeap{opts: opts, tail: tail, items: make([]rankedLine, 0, min(n, 1<<16))}
+
+	index := 0
+	for _, r := range inputs {
+		in := bufio.NewReader(r)
+		for {
+			text, err := readLine(in)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			h.offer(rankedLine{line: makeLine(text, opts), index: index}, n)
+			index++
+		}
+	}
+
+	sort.Slice(h.items, func(i, j int) bool {
+		return before(h.items[i], h.items[j], opts)
+	})
+	out := bufio.NewWriter(w)
+	lw := &lineWriter{w: out, opts: opts}
+	for _, item := range h.items {
+		if err := lw.write(item.line); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestSelectLines проверяет, что --head и --tail совпадают с началом и концом полной сортировки
+func TestSelectLines(t *testing.T) {
+	input := randomInput(rand.New(rand.NewSource(2)), 2000)
+
+	tests := []struct {
+		name string
+		opts SortOptions
+	}{
+		{name: "вся строка", opts: SortOptions{head: 10}},
+		{name: "числовой столбец по убыванию", opts: SortOptions{head: 25, keys: []keySpec{{startField: 2, endField: 2, numeric: true, reverse: true}}}},
+		{name: "стабильно с равными ключами", opts: SortOptions{head: 100, stable: true, keys: []keySpec{{startField: 1, endField: 1}}}},
+		{name: "последние строки", opts: SortOptions{tail: 10, keys: []keySpec{{startField: 3, endField: 3, humanNumeric: true}}}},
+		{name: "последние стабильно", opts: SortOptions{tail: 77, stable: true, keys: []keySpec{{startField: 2, endField: 2, numeric: true}}}},
+		{name: "больше строк, чем во входе", opts: SortOptions{head: 5000}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var full bytes.Buffer
+			if err := sortStream(strings.NewReader(input), &full, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			lines := strings.SplitAfter(full.String(), "\n")
+			lines = lines[:len(lines)-1]
+			if n := test.opts.head; n > 0 && n < len(lines) {
+				lines = lines[:n]
+			}
+			if n := test.opts.tail; n > 0 && n < len(lines) {
+				lines = lines[len(lines)-n:]
+			}
+			expected := strings.Join(lines, "")
+
+			var out bytes.Buffer
+			half := len(input) / 2
+			half += strings.IndexByte(input[half:], '\n') + 1
+			inputs := []io.Reader{strings.NewReader(input[:half]), strings.NewReader(input[half:])}
+			if err := selectLines(inputs, &out, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if out.String() != expected {
+				t.Errorf("ожидалось %q, получено %q", expected, out.String())
+			}
+		})
+	}
+}
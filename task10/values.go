@@ -0,0 +1,199 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// compareVersions сравнивает строки как версии: последовательности цифр
+// сравниваются как числа, остальные части — посимвольно, причём буквы идут
+// раньше прочих символов, а '~' — раньше всего, даже конца строки
+// (1.0~rc1 < 1.0), как в сравнении версий Debian.
+func compareVersions(a, b string) int {
+	for a != "" || b != "" {
+		var aText, bText string
+		aText, a = splitNonDigits(a)
+		bText, b = splitNonDigits(b)
+		if c := compareVersionText(aText, bText); c != 0 {
+			return c
+		}
+
+		var aNum, bNum string
+		aNum, a = splitDigits(a)
+		bNum, b = splitDigits(b)
+		if c := compareDigitRuns(aNum, bNum); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// splitNonDigits отделяет начало строки до первой цифры.
+func splitNonDigits(s string) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// splitDigits отделяет последовательность цифр в начале строки.
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareVersionText сравнивает нецифровые части версий.
+func compareVersionText(a, b string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var ac, bc int
+		if i < len(a) {
+			ac = versionOrder(a[i])
+		}
+		if i < len(b) {
+			bc = versionOrder(b[i])
+		}
+		if ac != bc {
+			return cmp.Compare(ac, bc)
+		}
+	}
+	return 0
+}
+
+// versionOrder возвращает вес символа версии: '~' меньше конца строки (0),
+// буквы меньше остальных символов.
+func versionOrder(c byte) int {
+	switch {
+	case c == '~':
+		return -1
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+// compareDigitRuns сравнивает последовательности цифр как числа любой длины.
+func compareDigitRuns(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return cmp.Compare(len(a), len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// randomHash возвращает хеш FNV-1a строки s, начатый с зерна seed и
+// перемешанный финализатором splitmix64.
+func randomHash(seed uint64, s string) uint64 {
+	h := seed ^ 14695981039346656037
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	return h ^ h>>31
+}
+
+// compareRandom сравнивает значения -R. Порядок задаёт хеш ключа с зерном,
+// поэтому равные ключи оказываются рядом, а при равных хешах ключи
+// сравниваются сами.
+func compareRandom(a, b *keyValue) int {
+	if result := cmp.Compare(a.order, b.order); result != 0 {
+		return result
+	}
+	return strings.Compare(a.text, b.text)
+}
+
+// generalValue — значение ключа при общей числовой сортировке (-g).
+// Нечисловые значения идут первыми, затем NaN, затем числа от -inf до +inf.
+type generalValue struct {
+	class int8 // 0 — не число, 1 — NaN, 2 — число
+	value float64
+}
+
+// parseGeneral разбирает начало строки как число с плавающей точкой,
+// включая экспоненту, inf и nan, как strtod в GNU sort -g. Десятичный
+// разделитель берётся из локали.
+func parseGeneral(s string, f numberFormat) generalValue {
+	s = strings.TrimLeft(s, " \t")
+	prefix := floatPrefix(s, f.point())
+	if f.point() != '.' {
+		prefix = strings.Replace(prefix, string(f.point()), ".", 1)
+	}
+	value, err := strconv.ParseFloat(prefix, 64)
+	if err != nil && !isRangeError(err) {
+		return generalValue{}
+	}
+	if math.IsNaN(value) {
+		return generalValue{class: 1}
+	}
+	return generalValue{class: 2, value: value}
+}
+
+// isRangeError сообщает о переполнении, при котором ParseFloat возвращает ±Inf или 0.
+func isRangeError(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+	return ok && numErr.Err == strconv.ErrRange
+}
+
+// floatPrefix возвращает самое длинное начало строки, похожее на число
+// с десятичным разделителем point.
+func floatPrefix(s string, point rune) string {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	lower := strings.ToLower(s[i:])
+	for _, word := range []string{"infinity", "inf", "nan"} {
+		if strings.HasPrefix(lower, word) {
+			return s[:i+len(word)]
+		}
+	}
+
+	digits := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+		digits++
+	}
+	if r, size := utf8.DecodeRuneInString(s[i:]); r == point {
+		i += size
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return ""
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			i = j
+		}
+	}
+	return s[:i]
+}
+
+// compareGeneral сравнивает значения -g.
+func compareGeneral(a, b generalValue) int {
+	if a.class != b.class {
+		return cmp.Compare(a.class, b.class)
+	}
+	return cmp.Compare(a.value, b.value)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestCompareVersions тестирует сравнение версий
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "v1.9.0", b: "v1.10.2", expected: -1},
+		{a: "1.2", b: "1.2", expected: 0},
+		{a: "1.02", b: "1.2", expected: 0},
+		{a: "1.0~rc1", b: "1.0", expected: -1},
+		{a: "1.0", b: "1.0a", expected: -1},
+		{a: "1.0a", b: "1.0+", expected: -1},
+		{a: "file10.txt", b: "file9.txt", expected: 1},
+		{a: "99999999999999999999999", b: "100000000000000000000000", expected: -1},
+		{a: "", b: "0", expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			if result := compareVersions(test.a, test.b); result != test.expected {
+				t.Errorf("ожидалось %d, получено %d", test.expected, result)
+			}
+			if result := compareVersions(test.b, test.a); result != -test.expected {
+				t.Errorf("обратное сравнение: ожидалось %d, получено %d", -test.expected, result)
+			}
+		})
+	}
+}
+
+// TestParseGeneral тестирует разбор чисел для -g
+func TestParseGeneral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected generalValue
+	}{
+		{input: "1e-3", expected: generalValue{class: 2, value: 0.001}},
+		{input: "  -2.5E2xyz", expected: generalValue{class: 2, value: -250}},
+		{input: "inf", expected: generalValue{class: 2, value: math.Inf(1)}},
+		{input: "-Infinity", expected: generalValue{class: 2, value: math.Inf(-1)}},
+		{input: "1e999", expected: generalValue{class: 2, value: math.Inf(1)}},
+		{input: "NaN", expected: generalValue{class: 1}},
+		{input: "5e", expected: generalValue{class: 2, value: 5}},
+		{input: "N/A", expected: generalValue{}},
+		{input: ".", expected: generalValue{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if result := parseGeneral(test.input, numberFormat{}); result != test.expected {
+				t.Errorf("ожидалось %+v, получено %+v", test.expected, result)
+			}
+		})
+	}
+}
+
+// TestSortVersionAndGeneral тестирует -V и -g как глобальные опции и модификаторы ключа
+func TestSortVersionAndGeneral(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		opts     SortOptions
+		expected []string
+	}{
+		{
+			name:     "версии",
+			input:    []string{"v1.10.2", "v1.9.0", "v1.9.0-rc1", "v2.0"},
+			opts:     SortOptions{version: true},
+			expected: []string{"v1.9.0", "v1.9.0-rc1", "v1.10.2", "v2.0"},
+		},
+		{
+			name:     "общие числа",
+			input:    []string{"1e-3", "inf", "abc", "-inf", "nan", "2.5", "1E2"},
+			opts:     SortOptions{general: true},
+			expected: []string{"abc", "nan", "-inf", "1e-3", "2.5", "1E2", "inf"},
+		},
+		{
+			name:     "модификаторы ключей",
+			input:    []string{"b 1e2 v1.2", "a 5 v1.10", "a 5 v1.9"},
+			opts:     SortOptions{keys: []keySpec{{startField: 2, endField: 2, general: true, reverse: true}, {startField: 3, endField: 3, version: true}}},
+			expected: []string{"b 1e2 v1.2", "a 5 v1.9", "a 5 v1.10"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := sortStream(strings.NewReader(strings.Join(test.input, "\n")), &out, test.opts); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			expected := strings.Join(test.expected, "\n") + "\n"
+			if out.String() != expected {
+				t.Errorf("ожидалось %q, получено %q", expected, out.String())
+			}
+		})
+	}
+}
+
+// TestRandomSort проверяет, что -R повторяем при одном зерне и держит равные ключи рядом
+func TestRandomSort(t *testing.T) {
+	input := "a\t1\nb\t2\nc\t1\nd\t3\ne\t2\nf\t1\ng\t4\nh\t5\n"
+	sortWith := func(seed uint64) string {
+		opts := SortOptions{seed: seed, stable: true, keys: []keySpec{{startField: 2, endField: 2, random: true}}}
+		var out bytes.Buffer
+		if err := sortStream(strings.NewReader(input), &out, opts); err != nil {
+			t.Fatalf("ожидалось nil, получено: %v", err)
+		}
+		return out.String()
+	}
+
+	first := sortWith(1)
+	if again := sortWith(1); again != first {
+		t.Errorf("ожидалось %q, получено %q", first, again)
+	}
+	differs := false
+	for seed := uint64(2); seed < 10; seed++ {
+		differs = differs || sortWith(seed) != first
+	}
+	if !differs {
+		t.Errorf("порядок не зависит от зерна: %q", first)
+	}
+
+	// Ключи одной группы не разделяются строками с другими ключами
+	seen := map[string]bool{}
+	last := ""
+	for _, line := range strings.Split(strings.TrimSuffix(first, "\n"), "\n") {
+		key := line[strings.IndexByte(line, '\t')+1:]
+		if key != last && seen[key] {
+			t.Fatalf("ключ %q разделён другими строками: %q", key, first)
+		}
+		seen[key], last = true, key
+	}
+}
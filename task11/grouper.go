@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Grouper собирает группы анаграмм из потока слов. Ключ группы — первое
+// встреченное слово. Промежуточные строки с отсортированными буквами не
+// строятся: группа ищется по подписи — хешу мультимножества букв, который
+// не зависит от их порядка, — а совпадение проверяется сравнением букв
+// в буфере на стеке. Поэтому на слово в памяти остаётся только оно само
+// в исходном написании.
+type Grouper struct {
+	norm   Normalization
+	index  map[uint64]int32 // подпись → последняя группа с такой подписью
+	groups []anagramGroup   // в порядке появления ключей
+}
+
+// anagramGroup — группа анаграмм; группы с одной подписью связаны в список.
+type anagramGroup struct {
+	key    string
+	others []string // остальные слова группы в порядке появления, с повторами
+	next   int32    // предыдущая группа с той же подписью, -1 — нет
+}
+
+// NewGrouper создаёт пустой Grouper, сравнивающий слова с учётом n.
+func NewGrouper(n Normalization) *Grouper {
+	return &Grouper{norm: n, index: make(map[uint64]int32)}
+}
+
+// Add добавляет слово в его группу. Слово, в котором после нормализации
+// не осталось букв, пропускается.
+func (g *Grouper) Add(word string) {
+	form := g.norm.apply(word)
+	if form == "" {
+		return
+	}
+	sig := signature(form)
+	head, found := g.index[sig]
+	if found {
+		for i := head; i >= 0; i = g.groups[i].next {
+			group := &g.groups[i]
+			if sameLetters(g.norm.apply(group.key), form) {
+				group.others = append(group.others, word)
+				return
+			}
+		}
+	} else {
+		head = -1
+	}
+	g.index[sig] = int32(len(g.groups))
+	g.groups = append(g.groups, anagramGroup{key: word, next: head})
+}
+
+// AddFrom добавляет слова из r, по одному на строку; пустые строки пропускаются.
+func (g *Grouper) AddFrom(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			g.Add(word)
+		}
+	}
+	return scanner.Err()
+}
+
+// Each вызывает fn для каждой группы из двух и более слов в порядке появления
+// ключей; слова группы, включая ключ, отсортированы.
+func (g *Grouper) Each(fn func(key string, words []string) error) error {
+	for _, group := range g.groups {
+		if len(group.others) == 0 {
+			continue
+		}
+		words := append([]string{group.key}, group.others...)
+		slices.Sort(words)
+		if err := fn(group.key, words); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signature возвращает хеш мультимножества символов слова: сумма
+// перемешанных кодов символов не зависит от их порядка.
+func signature(word string) uint64 {
+	var sum uint64
+	for _, r := range word {
+		sum += mix(uint64(r))
+	}
+	return sum
+}
+
+// mix — финализатор splitmix64.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	return h ^ h>>31
+}
+
+// sameLetters сообщает, состоят ли слова из одних и тех же символов.
+// Слова до 64 символов сравниваются без выделения памяти.
+func sameLetters(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var bufA, bufB [64]rune
+	runesA, runesB := bufA[:0], bufB[:0]
+	for _, r := range a {
+		runesA = append(runesA, r)
+	}
+	for _, r := range b {
+		runesB = append(runesB, r)
+	}
+	slices.Sort(runesA)
+	slices.Sort(runesB)
+	return slices.Equal(runesA, runesB)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// UnicodeForm — нормальная форма Unicode, к которой приводятся слова перед
+// сравнением, чтобы составной "й" и "и" с комбинируемой краткой совпадали.
+type UnicodeForm uint8
+
+const (
+	FormNone UnicodeForm = iota // без нормализации
+	FormNFC                     // каноническая композиция
+	FormNFD                     // каноническая декомпозиция: знаки — отдельные символы
+)
+
+// ParseForm разбирает имя формы: "", "none", "nfc" или "nfd".
+func ParseForm(s string) (UnicodeForm, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return FormNone, nil
+	case "nfc":
+		return FormNFC, nil
+	case "nfd":
+		return FormNFD, nil
+	}
+	return 0, fmt.Errorf("unknown form %q", s)
+}
+
+// Normalization задаёт, какие различия в написании не мешают словам быть
+// анаграммами. Регистр не учитывается всегда; нулевое значение больше
+// ничего не меняет. Нормализация влияет только на сравнение: в группах
+// слова остаются в исходном написании.
+type Normalization struct {
+	Form        UnicodeForm
+	FoldYo      bool // ё и е — одна буква
+	IgnoreSpace bool // пробелы и знаки препинания не считаются: анаграммы фраз
+	StripMarks  bool // диакритические знаки отбрасываются: é — e, й — и, ё — е
+}
+
+// stripMarks удаляет из строки диакритические знаки и собирает остаток обратно.
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// apply возвращает форму слова, по буквам которой ищутся анаграммы.
+func (n Normalization) apply(word string) string {
+	switch {
+	case n.StripMarks:
+		word, _, _ = transform.String(stripMarks, word)
+	case n.Form == FormNFC:
+		word = norm.NFC.String(word)
+	case n.Form == FormNFD:
+		word = norm.NFD.String(word)
+	}
+	word = strings.ToLower(word)
+	if n.FoldYo {
+		// В NFD ё записана как е с комбинируемым диерезисом
+		word = strings.ReplaceAll(strings.ReplaceAll(word, "ё", "е"), "е\u0308", "е")
+	}
+	if n.IgnoreSpace {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) || unicode.IsPunct(r) {
+				return -1
+			}
+			return r
+		}, word)
+	}
+	return word
+}
@@ -1,68 +1,121 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"sort"
+	"io"
+	"os"
 	"strings"
 )
 
-func main() {
-	arr := []string{"пятак", "пятка", "тяпка", "листок", "слиток", "столик", "стол"}
-	fmt.Println(SearchAnagramms(arr))
-	// map[листок:[листок слиток столик] пятак:[пятак пятка тяпка]]
-	arr1 := []string{"тяпка", "пятка", "пятак", "листок", "слиток", "столик", "стол"}
-	fmt.Println(SearchAnagramms(arr1))
-	// map[листок:[листок слиток столик] тяпка:[пятак пятка тяпка]]
-}
-
-// SearchAnagramms находит группы анаграмм в массиве строк.
-func SearchAnagramms(arr []string) map[string][]string {
-	res := make(map[string][]string)
-	sortedMap := make(map[string]string) // [sorted]original
+// printf 'пятак\nпятка\nтяпка\nлисток\nслиток\nстолик\nстол\n' | go run .
+// # листок: листок слиток столик
+// # пятак: пятак пятка тяпка
+// go run . -format=json words.txt
+// printf 'Ёлка\nкелА\nDormitory\ndirty room!\n' | go run . -yo -phrases
 
-	// Находим анаграммы путём сортировки букв в словах
-	for i, x := range arr {
-		if i == 0 {
-			x = strings.ToLower(x)
-			res[x] = make([]string, 0, 1)
-			res[x] = append(res[x], x)
+func main() {
+	var (
+		format      = flag.String("format", "text", "формат вывода: text (ключ: слова) или json")
+		form        = flag.String("form", "", "нормальная форма Unicode перед сравнением: nfc или nfd")
+		foldYo      = flag.Bool("yo", false, "не различать ё и е")
+		ignoreSpace = flag.Bool("phrases", false, "не учитывать пробелы и знаки препинания (анаграммы фраз)")
+		stripMarks  = flag.Bool("strip-marks", false, "не учитывать диакритические знаки: é — e, й — и")
+	)
+	flag.Parse()
 
-			sorted := sortStrings(x)
-			sortedMap[sorted] = x
-		} else {
-			x = strings.ToLower(x)
+	unicodeForm, err := ParseForm(*form)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: неверный -form: %v\n", err)
+		os.Exit(2)
+	}
 
-			sorted := sortStrings(x)
+	var write func(io.Writer, *Grouper) error
+	switch *format {
+	case "text":
+		write = writeText
+	case "json":
+		write = writeJSON
+	default:
+		fmt.Fprintf(os.Stderr, "Ошибка: неверный -format: %q\n", *format)
+		os.Exit(2)
+	}
 
-			orig, ok := sortedMap[sorted]
-			if ok {
-				res[orig] = append(res[orig], x)
-			} else {
-				res[x] = make([]string, 0, 1)
-				res[x] = append(res[x], x)
-				sortedMap[sorted] = x
-			}
+	var in io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка открытия файла: %v\n", err)
+			os.Exit(1)
 		}
+		defer file.Close()
+		in = file
 	}
 
-	// Удаляем множества из 1 слова и сортируем остальные
-	for k, v := range res {
-		if len(v) == 1 {
-			delete(res, k)
-		} else {
-			sort.Strings(v)
-		}
+	g := NewGrouper(Normalization{
+		Form:        unicodeForm,
+		FoldYo:      *foldYo,
+		IgnoreSpace: *ignoreSpace,
+		StripMarks:  *stripMarks,
+	})
+	if err := g.AddFrom(in); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка чтения: %v\n", err)
+		os.Exit(1)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	err = write(out, g)
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка записи: %v\n", err)
+		os.Exit(1)
 	}
-	return res
 }
 
-// sortStrings сортирует символы в строке по возрастанию.
-func sortStrings(str string) string {
-	runes := []rune(str)
+// writeText выводит группы строками "ключ: слово1 слово2 ...".
+func writeText(w io.Writer, g *Grouper) error {
+	return g.Each(func(key string, words []string) error {
+		_, err := fmt.Fprintf(w, "%s: %s\n", key, strings.Join(words, " "))
+		return err
+	})
+}
 
-	sort.Slice(runes, func(i, j int) bool {
-		return runes[i] < runes[j]
+// writeJSON выводит группы объектом JSON, по группе на строку.
+func writeJSON(w io.Writer, g *Grouper) error {
+	sep := "{\n"
+	err := g.Each(func(key string, words []string) error {
+		k, _ := json.Marshal(key)
+		v, _ := json.Marshal(words)
+		_, err := fmt.Fprintf(w, "%s  %s: %s", sep, k, v)
+		sep = ",\n"
+		return err
 	})
+	if err != nil {
+		return err
+	}
+	if sep == "{\n" {
+		_, err = io.WriteString(w, "{}\n")
+	} else {
+		_, err = io.WriteString(w, "\n}\n")
+	}
+	return err
+}
 
-	return string(runes)
+// SearchAnagramms находит группы анаграмм в массиве строк. Слова приводятся
+// к нижнему регистру, ключ группы — первое встреченное слово, слова группы
+// отсортированы, группы из одного слова не попадают в результат.
+func SearchAnagramms(arr []string) map[string][]string {
+	g := NewGrouper(Normalization{})
+	for _, word := range arr {
+		g.Add(strings.ToLower(word))
+	}
+	res := make(map[string][]string)
+	g.Each(func(key string, words []string) error {
+		res[key] = words
+		return nil
+	})
+	return res
 }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSearchAnagramms тестирует поиск групп анаграмм
+func TestSearchAnagramms(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected map[string][]string
+	}{
+		{
+			name:  "пример из задания",
+			input: []string{"пятак", "пятка", "тяпка", "листок", "слиток", "столик", "стол"},
+			expected: map[string][]string{
+				"пятак":  {"пятак", "пятка", "тяпка"},
+				"листок": {"листок", "слиток", "столик"},
+			},
+		},
+		{
+			name:  "ключ — первое слово",
+			input: []string{"тяпка", "пятка", "пятак"},
+			expected: map[string][]string{
+				"тяпка": {"пятак", "пятка", "тяпка"},
+			},
+		},
+		{
+			name:  "регистр и повторы",
+			input: []string{"Пятак", "пятак", "ТЯПКА", "тяпка"},
+			expected: map[string][]string{
+				"пятак": {"пятак", "пятак", "тяпка", "тяпка"},
+			},
+		},
+		{
+			name:     "одно слово дважды",
+			input:    []string{"пятак", "пятак"},
+			expected: map[string][]string{"пятак": {"пятак", "пятак"}},
+		},
+		{
+			name:     "разная длина и одиночные слова",
+			input:    []string{"стол", "столб", "кот", "ток", "окт", "кто", "а"},
+			expected: map[string][]string{"кот": {"кот", "кто", "окт", "ток"}},
+		},
+		{
+			name:     "пустой ввод",
+			input:    nil,
+			expected: map[string][]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := SearchAnagramms(test.input)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("ожидалось %v, получено %v", test.expected, result)
+			}
+		})
+	}
+}
+
+// TestSameLetters тестирует сравнение наборов букв, в том числе длинных слов
+func TestSameLetters(t *testing.T) {
+	long := strings.Repeat("абв", 30)
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "пятак", b: "тяпка", expected: true},
+		{a: "пятак", b: "пятаа", expected: false},
+		{a: "ab", b: "aab", expected: false},
+		{a: long, b: strings.Repeat("вба", 30), expected: true},
+		{a: long, b: strings.Repeat("вбб", 30), expected: false},
+	}
+
+	for _, test := range tests {
+		if result := sameLetters(test.a, test.b); result != test.expected {
+			t.Errorf("%q и %q: ожидалось %v, получено %v", test.a, test.b, test.expected, result)
+		}
+	}
+}
+
+// TestWriteGroups тестирует вывод групп из потока слов
+func TestWriteGroups(t *testing.T) {
+	input := "пятак\n\n  пятка \nлисток\nслиток\n\"а\nа\"\n"
+	tests := []struct {
+		name     string
+		write    func(*strings.Builder, *Grouper) error
+		expected string
+	}{
+		{
+			name:     "текст",
+			write:    func(b *strings.Builder, g *Grouper) error { return writeText(b, g) },
+			expected: "пятак: пятак пятка\nлисток: листок слиток\n\"а: \"а а\"\n",
+		},
+		{
+			name:     "JSON",
+			write:    func(b *strings.Builder, g *Grouper) error { return writeJSON(b, g) },
+			expected: "{\n  \"пятак\": [\"пятак\",\"пятка\"],\n  \"листок\": [\"листок\",\"слиток\"],\n  \"\\\"а\": [\"\\\"а\",\"а\\\"\"]\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewGrouper(Normalization{})
+			if err := g.AddFrom(strings.NewReader(input)); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			var b strings.Builder
+			if err := test.write(&b, g); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if b.String() != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, b.String())
+			}
+		})
+	}
+}
+
+// TestAddNoAllocs проверяет, что поиск группы не строит промежуточных строк
+func TestAddNoAllocs(t *testing.T) {
+	g := NewGrouper(Normalization{})
+	g.Add("пятак")
+	g.Add("тяпка")
+	allocs := testing.AllocsPerRun(100, func() {
+		g.Add("пятка")
+		g.Add("тяпка")
+	})
+	if allocs != 0 {
+		t.Errorf("ожидалось 0 выделений памяти, получено %v", allocs)
+	}
+}
+
+// TestNormalization тестирует приведение слов перед сравнением
+func TestNormalization(t *testing.T) {
+	tests := []struct {
+		name     string
+		norm     Normalization
+		input    string
+		expected string
+	}{
+		{name: "только регистр", norm: Normalization{}, input: "Ёлка", expected: "ёлка"},
+		{name: "NFC", norm: Normalization{Form: FormNFC}, input: "и\u0306од", expected: "йод"},
+		{name: "NFD", norm: Normalization{Form: FormNFD}, input: "йод", expected: "и\u0306од"},
+		{name: "ё как е", norm: Normalization{FoldYo: true}, input: "Ёлка", expected: "елка"},
+		{name: "ё как е в NFD", norm: Normalization{Form: FormNFD, FoldYo: true}, input: "ёлка", expected: "елка"},
+		{name: "фраза", norm: Normalization{IgnoreSpace: true}, input: "Dirty room, ok!", expected: "dirtyroomok"},
+		{name: "без диакритики", norm: Normalization{StripMarks: true}, input: "Café йод", expected: "cafe иод"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := test.norm.apply(test.input); result != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, result)
+			}
+		})
+	}
+}
+
+// TestGrouperNormalization проверяет, что группы собираются по нормализованным
+// словам, а выводятся в исходном написании
+func TestGrouperNormalization(t *testing.T) {
+	tests := []struct {
+		name     string
+		norm     Normalization
+		input    []string
+		expected string
+	}{
+		{
+			name:     "без нормализации",
+			input:    []string{"Ёлка", "келА", "елка"},
+			expected: "келА: елка келА\n",
+		},
+		{
+			name:     "ё как е",
+			norm:     Normalization{FoldYo: true},
+			input:    []string{"Ёлка", "келА", "елка", "Ёлка"},
+			expected: "Ёлка: Ёлка Ёлка елка келА\n",
+		},
+		{
+			name:     "анаграммы фраз",
+			norm:     Normalization{IgnoreSpace: true},
+			input:    []string{"Dormitory", "dirty room!", "!!!", "..."},
+			expected: "Dormitory: Dormitory dirty room!\n",
+		},
+		{
+			name:     "разложенный символ без нормализации",
+			input:    []string{"йод", "ди\u0306о"},
+			expected: "",
+		},
+		{
+			name:     "составные и разложенные символы",
+			norm:     Normalization{Form: FormNFC},
+			input:    []string{"йод", "ди\u0306о"},
+			expected: "йод: ди\u0306о йод\n",
+		},
+		{
+			name:     "диакритика",
+			norm:     Normalization{StripMarks: true},
+			input:    []string{"café", "face", "ДОЙ", "иод"},
+			expected: "café: café face\nДОЙ: ДОЙ иод\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewGrouper(test.norm)
+			for _, word := range test.input {
+				g.Add(word)
+			}
+			var b strings.Builder
+			if err := writeText(&b, g); err != nil {
+				t.Fatalf("ожидалось nil, получено: %v", err)
+			}
+			if b.String() != test.expected {
+				t.Errorf("ожидалось %q, получено %q", test.expected, b.String())
+			}
+		})
+	}
+}
+
+// TestParseForm тестирует разбор имени нормальной формы
+func TestParseForm(t *testing.T) {
+	for input, expected := range map[string]UnicodeForm{"": FormNone, "none": FormNone, "NFC": FormNFC, "nfd": FormNFD} {
+		if result, err := ParseForm(input); err != nil || result != expected {
+			t.Errorf("%q: ожидалось %v, получено %v (%v)", input, expected, result, err)
+		}
+	}
+	if _, err := ParseForm("nfkc"); err == nil {
+		t.Errorf("ожидалась ошибка для nfkc")
+	}
+}
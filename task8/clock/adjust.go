@@ -0,0 +1,110 @@
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultStepThreshold — смещение, начиная с которого часы переводятся
+	// скачком, а не подстраиваются плавно (как step threshold в ntpd).
+	DefaultStepThreshold = 128 * time.Millisecond
+	// slewRate — максимальная скорость плавной подстройки ядра Linux (500 ppm).
+	slewRate = 500e-6
+)
+
+// ErrPanicThreshold возвращается, если смещение превышает порог паники.
+var ErrPanicThreshold = errors.New("clock offset exceeds panic threshold")
+
+// AdjustMode определяет способ коррекции системных часов.
+type AdjustMode int
+
+const (
+	AdjustAuto AdjustMode = iota // скачок или подстройка в зависимости от StepThreshold
+	AdjustStep                   // всегда скачок (settimeofday)
+	AdjustSlew                   // всегда плавная подстройка (adjtimex)
+)
+
+// AdjustOptions содержит параметры коррекции системных часов.
+type AdjustOptions struct {
+	Mode           AdjustMode
+	StepThreshold  time.Duration // по умолчанию DefaultStepThreshold
+	PanicThreshold time.Duration // 0 — без ограничения
+	DryRun         bool          // только показать, что будет сделано
+}
+
+// Adjustment описывает запланированную или выполненную коррекцию часов.
+type Adjustment struct {
+	Offset   time.Duration // на сколько нужно сдвинуть часы
+	Step     bool          // true — скачок, false — плавная подстройка
+	SlewTime time.Duration // оценка длительности плавной подстройки
+	Applied  bool          // коррекция выполнена (false в режиме DryRun)
+}
+
+// String описывает коррекцию в человекочитаемом виде.
+func (a Adjustment) String() string {
+	offset := a.Offset.String()
+	if a.Offset >= 0 {
+		offset = "+" + offset
+	}
+	switch {
+	case a.Step && a.Applied:
+		return fmt.Sprintf("stepped clock by %s", offset)
+	case a.Step:
+		return fmt.Sprintf("would step clock by %s", offset)
+	case a.Applied:
+		return fmt.Sprintf("slewing clock by %s over ~%s", offset, a.SlewTime.Round(time.Second))
+	default:
+		return fmt.Sprintf("would slew clock by %s over ~%s", offset, a.SlewTime.Round(time.Second))
+	}
+}
+
+// PlanAdjustment выбирает способ коррекции для смещения offset.
+func PlanAdjustment(offset time.Duration, opts AdjustOptions) (Adjustment, error) {
+	abs := offset
+	if abs < 0 {
+		abs = -abs
+	}
+	if opts.PanicThreshold > 0 && abs > opts.PanicThreshold {
+		return Adjustment{}, fmt.Errorf("%w: %s > %s", ErrPanicThreshold, offset, opts.PanicThreshold)
+	}
+
+	threshold := opts.StepThreshold
+	if threshold == 0 {
+		threshold = DefaultStepThreshold
+	}
+
+	a := Adjustment{Offset: offset}
+	switch opts.Mode {
+	case AdjustStep:
+		a.Step = true
+	case AdjustSlew:
+		a.Step = false
+	default:
+		a.Step = abs >= threshold
+	}
+	if !a.Step {
+		a.SlewTime = time.Duration(float64(abs) / slewRate)
+	}
+	return a, nil
+}
+
+// Adjust корректирует системные часы на смещение из res. Требует прав
+// CAP_SYS_TIME, кроме режима DryRun.
+func Adjust(res *Result, opts AdjustOptions) (Adjustment, error) {
+	a, err := PlanAdjustment(res.Offset, opts)
+	if err != nil || opts.DryRun {
+		return a, err
+	}
+	if a.Step {
+		err = stepClock(a.Offset)
+	} else {
+		err = slewClock(a.Offset)
+	}
+	if err != nil {
+		return a, err
+	}
+	a.Applied = true
+	return a, nil
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// stepClock переводит системные часы скачком через settimeofday.
+func stepClock(offset time.Duration) error {
+	tv := unix.NsecToTimeval(time.Now().Add(offset).UnixNano())
+	if err := unix.Settimeofday(&tv); err != nil {
+		return clockError("settimeofday", err)
+	}
+	return nil
+}
+
+// slewClock запускает плавную подстройку часов через adjtimex
+// (ADJ_OFFSET_SINGLESHOT, аналог adjtime).
+func slewClock(offset time.Duration) error {
+	tx := unix.Timex{Modes: unix.ADJ_OFFSET_SINGLESHOT, Offset: offset.Microseconds()}
+	if _, err := unix.Adjtimex(&tx); err != nil {
+		return clockError("adjtimex", err)
+	}
+	return nil
+}
+
+// clockError поясняет ошибку нехватки прав.
+func clockError(call string, err error) error {
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("%s: %w (CAP_SYS_TIME is required)", call, err)
+	}
+	return fmt.Errorf("%s: %w", call, err)
+}
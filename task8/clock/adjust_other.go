@@ -0,0 +1,16 @@
+//go:build !linux
+
+package clock
+
+import (
+	"errors"
+	"time"
+)
+
+func stepClock(offset time.Duration) error {
+	return errors.ErrUnsupported
+}
+
+func slewClock(offset time.Duration) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,57 @@
+package clock
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPlanAdjustment(t *testing.T) {
+	tests := []struct {
+		name     string
+		offset   time.Duration
+		opts     AdjustOptions
+		wantStep bool
+		wantErr  error
+	}{
+		{name: "small offset slews", offset: 50 * time.Millisecond, opts: AdjustOptions{}, wantStep: false},
+		{name: "large offset steps", offset: -2 * time.Second, opts: AdjustOptions{}, wantStep: true},
+		{name: "custom threshold", offset: 50 * time.Millisecond, opts: AdjustOptions{StepThreshold: 10 * time.Millisecond}, wantStep: true},
+		{name: "forced slew", offset: 2 * time.Second, opts: AdjustOptions{Mode: AdjustSlew}, wantStep: false},
+		{name: "forced step", offset: time.Millisecond, opts: AdjustOptions{Mode: AdjustStep}, wantStep: true},
+		{name: "panic", offset: time.Hour, opts: AdjustOptions{PanicThreshold: 1000 * time.Second}, wantErr: ErrPanicThreshold},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := PlanAdjustment(test.offset, test.opts)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if a.Step != test.wantStep {
+				t.Errorf("expected step=%v, got %v", test.wantStep, a.Step)
+			}
+		})
+	}
+
+	a, _ := PlanAdjustment(50*time.Millisecond, AdjustOptions{})
+	if a.SlewTime != 100*time.Second {
+		t.Errorf("expected slew time 100s, got %s", a.SlewTime)
+	}
+}
+
+func TestAdjustDryRun(t *testing.T) {
+	res := &Result{Offset: 3 * time.Second}
+	a, err := Adjust(res, AdjustOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if a.Applied || !a.Step {
+		t.Errorf("unexpected adjustment: %+v", a)
+	}
+	if want := "would step clock by +3s"; a.String() != want {
+		t.Errorf("expected %q, got %q", want, a.String())
+	}
+}
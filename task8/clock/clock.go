@@ -0,0 +1,207 @@
+// Package clock получает точное время с набора NTP-серверов.
+package clock
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	defaultPort    = 123
+)
+
+// DefaultServers — серверы, которые опрашиваются, если список не задан явно.
+var DefaultServers = []string{
+	"0.beevik-ntp.pool.ntp.org",
+	"1.beevik-ntp.pool.ntp.org",
+	"2.beevik-ntp.pool.ntp.org",
+	"3.beevik-ntp.pool.ntp.org",
+}
+
+// Options содержит параметры одного опроса NTP-серверов.
+type Options struct {
+	Servers []string      // адреса серверов, по умолчанию DefaultServers
+	Timeout time.Duration // таймаут ответа одного сервера, по умолчанию 5s
+	Version int           // версия протокола NTP, по умолчанию 4
+	Port    int           // порт для адресов без явного порта, по умолчанию 123
+
+	// Auth включает аутентификацию симметричным ключом (MD5, SHA1 и др.).
+	Auth ntp.AuthOptions
+	// NTS включает Network Time Security (RFC 8915): Servers содержат адреса
+	// NTS-KE (порт 4460 по умолчанию), а незащищённые запросы не выполняются.
+	NTS bool
+	// TLSConfig задаёт параметры TLS для NTS-KE, например собственные корневые сертификаты.
+	TLSConfig *tls.Config
+}
+
+// servers возвращает список серверов с учётом значения по умолчанию.
+func (o Options) servers() []string {
+	if len(o.Servers) == 0 {
+		return DefaultServers
+	}
+	return o.Servers
+}
+
+// address добавляет к адресу сервера порт из опций, если он не указан явно.
+func (o Options) address(server string) string {
+	if o.Port == 0 {
+		return server
+	}
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, fmt.Sprint(o.Port))
+}
+
+// timeout возвращает таймаут с учётом значения по умолчанию.
+func (o Options) timeout() time.Duration {
+	if o.Timeout == 0 {
+		return defaultTimeout
+	}
+	return o.Timeout
+}
+
+// ntpPort возвращает порт NTP с учётом значения по умолчанию.
+func (o Options) ntpPort() int {
+	if o.Port == 0 {
+		return defaultPort
+	}
+	return o.Port
+}
+
+// queryOptions переводит опции в формат библиотеки ntp.
+func (o Options) queryOptions() ntp.QueryOptions {
+	return ntp.QueryOptions{
+		Timeout: o.Timeout,
+		Version: o.Version,
+		Auth:    o.Auth,
+	}
+}
+
+// query опрашивает один сервер и проверяет ответ. Ошибки аутентификации
+// возвращаются как есть: повторного запроса без защиты не делается.
+func (o Options) query(server string) (*ntp.Response, error) {
+	addr, qopts := o.address(server), o.queryOptions()
+	if o.NTS {
+		ext, ntpAddr, err := queryNTS(server, o)
+		if err != nil {
+			return nil, err
+		}
+		addr, qopts.Extensions = ntpAddr, []ntp.Extension{ext}
+	}
+
+	resp, err := ntp.QueryWithOptions(addr, qopts)
+	if err == nil {
+		err = resp.Validate()
+	}
+	if err != nil {
+		if o.NTS {
+			forgetNTS(server)
+		}
+		if errors.Is(err, ntp.ErrAuthFailed) {
+			return nil, fmt.Errorf("symmetric key %d: %w", o.Auth.KeyID, err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// PrintCurrentTime получает текущее время с NTP-серверов и выводит его в w
+// в формате out.
+func PrintCurrentTime(w io.Writer, opts Options, out Output) error {
+	res, err := Query(opts)
+	if err != nil {
+		return err
+	}
+	s, err := out.Render(res.Time(), res)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, s)
+	return err
+}
+
+// CurrentTime параллельно опрашивает все NTP-серверы и вычисляет текущее время
+// по отфильтрованным ответам.
+func CurrentTime(opts Options) (time.Time, error) {
+	res, err := Query(opts)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return res.Time(), nil
+}
+
+// Result содержит итог опроса NTP-серверов.
+type Result struct {
+	Offset   time.Duration // смещение локальных часов относительно NTP-времени
+	Server   string        // лучший из согласованных серверов
+	Response *ntp.Response // полный ответ лучшего сервера
+}
+
+// Time возвращает текущее время локальных часов, скорректированное на Offset.
+func (r *Result) Time() time.Time {
+	return time.Now().Add(r.Offset)
+}
+
+// Query параллельно опрашивает все NTP-серверы и возвращает итоговое смещение
+// локальных часов вместе с ответом лучшего сервера.
+func Query(opts Options) (*Result, error) {
+	samples, err := queryAll(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current time from all ntp-servers: %w", err)
+	}
+	offset, best, err := combineOffset(samples)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Offset: offset, Server: best.server, Response: best.response}, nil
+}
+
+// queryAll параллельно опрашивает серверы и возвращает все корректные ответы.
+// Ошибка возвращается, только если не ответил ни один сервер.
+func queryAll(opts Options) ([]sample, error) {
+	if opts.NTS && opts.Auth.Type != ntp.AuthNone {
+		return nil, errors.New("nts and symmetric key authentication cannot be combined")
+	}
+
+	servers := opts.servers()
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		samples = make([]sample, 0, len(servers))
+		errs    = make([]error, 0, len(servers))
+	)
+
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server string) {
+			defer wg.Done()
+			resp, err := opts.query(server)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", server, err))
+				return
+			}
+			samples = append(samples, sample{server: server, response: resp})
+		}(server)
+	}
+	wg.Wait()
+
+	if len(samples) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no ntp-servers configured")
+		}
+		return nil, errors.Join(errs...)
+	}
+	return samples, nil
+}
@@ -0,0 +1,198 @@
+package clock
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeServer запускает на localhost SNTP-сервер, часы которого
+// смещены относительно локальных на offset.
+func startFakeServer(t *testing.T, offset time.Duration) *net.UDPAddr {
+	t.Helper()
+	srv, err := NewServer(ServerOptions{Stratum: 2, RefID: "127.0.0.1", Source: LocalClock{Offset: offset}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startServer(t, srv)
+}
+
+// startServer запускает srv на случайном порту localhost до конца теста.
+func startServer(t *testing.T, srv *Server) *net.UDPAddr {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.Serve(ctx, conn)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestCurrentTime(t *testing.T) {
+	offset := time.Hour
+	addr := startFakeServer(t, offset)
+
+	opts := Options{
+		Servers: []string{"127.0.0.1"},
+		Port:    addr.Port,
+		Timeout: time.Second,
+	}
+	got, err := CurrentTime(opts)
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if diff := got.Sub(time.Now().Add(offset)); diff < -time.Second || diff > time.Second {
+		t.Errorf("expected time near now+%s, got diff %s", offset, diff)
+	}
+}
+
+func TestCurrentTimeAllFailed(t *testing.T) {
+	opts := Options{
+		Servers: []string{"127.0.0.1:1"},
+		Timeout: 200 * time.Millisecond,
+	}
+	if _, err := CurrentTime(opts); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestOptionsAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		server string
+		want   string
+	}{
+		{name: "without port", opts: Options{}, server: "pool.ntp.org", want: "pool.ntp.org"},
+		{name: "default port", opts: Options{Port: 1123}, server: "pool.ntp.org", want: "pool.ntp.org:1123"},
+		{name: "explicit port", opts: Options{Port: 1123}, server: "pool.ntp.org:123", want: "pool.ntp.org:123"},
+		{name: "ipv6", opts: Options{Port: 1123}, server: "::1", want: "[::1]:1123"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.opts.address(test.server); got != test.want {
+				t.Errorf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	conf := `# ntp.conf
+driftfile /var/lib/ntp/drift
+server 0.pool.ntp.org iburst
+pool europe.pool.ntp.org   # comment
+restrict default nomodify
+`
+	got, err := ParseConfig(strings.NewReader(conf))
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	want := []string{"0.pool.ntp.org", "europe.pool.ntp.org"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := ParseConfig(strings.NewReader("server\n")); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestResolveServers(t *testing.T) {
+	t.Setenv(EnvServers, "env1 env2")
+	got, err := ResolveServers("a,b", "")
+	if err != nil || strings.Join(got, ",") != "a,b" {
+		t.Errorf("expected flag servers, got %v (%v)", got, err)
+	}
+	got, err = ResolveServers("", "")
+	if err != nil || strings.Join(got, ",") != "env1,env2" {
+		t.Errorf("expected env servers, got %v (%v)", got, err)
+	}
+
+	// Явно указанный -config важнее переменной окружения
+	config := filepath.Join(t.TempDir(), "ntp.conf")
+	if err := os.WriteFile(config, []byte("server conf1\npool conf2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ResolveServers("", config)
+	if err != nil || strings.Join(got, ",") != "conf1,conf2" {
+		t.Errorf("expected config servers, got %v (%v)", got, err)
+	}
+	got, err = ResolveServers("a", config)
+	if err != nil || strings.Join(got, ",") != "a" {
+		t.Errorf("expected flag servers, got %v (%v)", got, err)
+	}
+
+	t.Setenv(EnvServers, "")
+	got, err = ResolveServers("", "")
+	if err != nil || len(got) != len(DefaultServers) {
+		t.Errorf("expected default servers, got %v (%v)", got, err)
+	}
+}
+
+func TestQueryReport(t *testing.T) {
+	addr := startFakeServer(t, 0)
+	res, err := Query(Options{Servers: []string{addr.String()}, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if res.Server != addr.String() {
+		t.Errorf("expected server %s, got %s", addr, res.Server)
+	}
+
+	var buf strings.Builder
+	if err := PrintReport(&buf, res); err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	for _, want := range []string{"Stratum:      2", "Reference ID: 127.0.0.1", "Leap:         none"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	addr := startFakeServer(t, 0)
+	opts := Options{Servers: []string{addr.String()}, Timeout: time.Second}
+
+	var buf strings.Builder
+	out, err := NewDriftWriter(&buf, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Watch(context.Background(), opts, 10*time.Millisecond, 3, out); err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 records, got %d:\n%s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		var rec DriftRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid json line %q: %v", line, err)
+		}
+		if rec.Err != "" || rec.Stratum != 2 {
+			t.Errorf("unexpected record: %+v", rec)
+		}
+	}
+
+	if _, err := NewDriftWriter(&buf, "xml"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
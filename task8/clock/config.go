@@ -0,0 +1,170 @@
+package clock
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/ntp"
+)
+
+// EnvServers — переменная окружения со списком серверов через запятую или пробел.
+const EnvServers = "NTP_SERVERS"
+
+// ParseServerList разбирает список серверов, разделённых запятыми или пробелами.
+func ParseServerList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// ServersFromEnv возвращает серверы из переменной окружения NTP_SERVERS.
+func ServersFromEnv() []string {
+	return ParseServerList(os.Getenv(EnvServers))
+}
+
+// ParseConfig читает серверы из конфигурации в формате ntp.conf.
+// Учитываются директивы server и pool, остальные строки пропускаются.
+func ParseConfig(r io.Reader) ([]string, error) {
+	var servers []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "server", "pool":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s directive without address", fields[0])
+			}
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers, scanner.Err()
+}
+
+// LoadConfig читает серверы из файла в формате ntp.conf.
+func LoadConfig(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	servers, err := ParseConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return servers, nil
+}
+
+// ResolveServers выбирает список серверов по приоритету: явный список из
+// флагов, явно указанный файл конфигурации, переменная окружения
+// NTP_SERVERS и, наконец, DefaultServers.
+func ResolveServers(flagServers, configPath string) ([]string, error) {
+	if servers := ParseServerList(flagServers); len(servers) > 0 {
+		return servers, nil
+	}
+	if configPath != "" {
+		servers, err := LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(servers) > 0 {
+			return servers, nil
+		}
+	}
+	if servers := ServersFromEnv(); len(servers) > 0 {
+		return servers, nil
+	}
+	return DefaultServers, nil
+}
+
+// authTypes сопоставляет названия алгоритмов из ntp.keys типам библиотеки ntp.
+var authTypes = map[string]ntp.AuthType{
+	"M":          ntp.AuthMD5,
+	"MD5":        ntp.AuthMD5,
+	"SHA1":       ntp.AuthSHA1,
+	"SHA256":     ntp.AuthSHA256,
+	"SHA512":     ntp.AuthSHA512,
+	"AES128CMAC": ntp.AuthAES128,
+	"AES256CMAC": ntp.AuthAES256,
+}
+
+// ParseKeys читает симметричные ключи в формате ntp.keys: "keyid type key".
+// Ключ длиннее 20 символов считается шестнадцатеричным, как в ntpd.
+func ParseKeys(r io.Reader) (map[uint16]ntp.AuthOptions, error) {
+	keys := make(map[uint16]ntp.AuthOptions)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected \"keyid type key\"", n)
+		}
+		id, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("line %d: invalid key id %q", n, fields[0])
+		}
+		typ, ok := authTypes[strings.ToUpper(fields[1])]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unsupported key type %q", n, fields[1])
+		}
+		keys[uint16(id)] = ntp.AuthOptions{Type: typ, Key: fields[2], KeyID: uint16(id)}
+	}
+	return keys, scanner.Err()
+}
+
+// LoadKey читает файл ключей и возвращает ключ с идентификатором keyID.
+func LoadKey(path string, keyID uint16) (ntp.AuthOptions, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ntp.AuthOptions{}, err
+	}
+	defer file.Close()
+
+	keys, err := ParseKeys(file)
+	if err != nil {
+		return ntp.AuthOptions{}, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := keys[keyID]
+	if !ok {
+		return ntp.AuthOptions{}, fmt.Errorf("%s: key %d not found", path, keyID)
+	}
+	return key, nil
+}
+
+// KeyID — идентификатор симметричного ключа, значение флага -keyid.
+// Как и в ParseKeys, больше 65535 не допускается, чтобы значение не
+// обрезалось до другого ключа; 0 — аутентификация не используется.
+type KeyID uint16
+
+func (id *KeyID) String() string {
+	if id == nil || *id == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(*id))
+}
+
+func (id *KeyID) Set(s string) error {
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid key id %q: must be in 1..65535", s)
+	}
+	*id = KeyID(v)
+	return nil
+}
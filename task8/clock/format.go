@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Format — формат вывода времени в PrintCurrentTime.
+type Format string
+
+const (
+	FormatDefault  Format = ""         // "Current time: ..." в формате time.Time.String
+	FormatRFC3339  Format = "rfc3339"  // RFC 3339 с наносекундами
+	FormatUnix     Format = "unix"     // секунды Unix
+	FormatUnixNano Format = "unixnano" // наносекунды Unix
+	FormatLayout   Format = "layout"   // произвольный layout Go из Output.Layout
+	FormatJSON     Format = "json"     // JSON с сервером и смещением
+)
+
+// ParseFormat проверяет название формата вывода.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatDefault, FormatRFC3339, FormatUnix, FormatUnixNano, FormatLayout, FormatJSON:
+		return f, nil
+	case "default":
+		return FormatDefault, nil
+	default:
+		return "", fmt.Errorf("unknown output format: %q", s)
+	}
+}
+
+// Output задаёт формат вывода и часовой пояс.
+type Output struct {
+	Format   Format
+	Layout   string         // layout для FormatLayout, например "2006-01-02 15:04:05"
+	Location *time.Location // часовой пояс, по умолчанию локальный
+}
+
+// timeJSON — представление результата в формате FormatJSON.
+type timeJSON struct {
+	Time     string        `json:"time"`
+	Unix     int64         `json:"unix"`
+	UnixNano int64         `json:"unix_nano"`
+	Server   string        `json:"server"`
+	Offset   time.Duration `json:"offset_ns"`
+	RTT      time.Duration `json:"rtt_ns"`
+	Stratum  uint8         `json:"stratum"`
+}
+
+// Render форматирует время t; res используется для FormatJSON.
+func (o Output) Render(t time.Time, res *Result) (string, error) {
+	if o.Location != nil {
+		t = t.In(o.Location)
+	}
+	switch o.Format {
+	case FormatDefault:
+		return fmt.Sprintf("Current time: %s", t.Round(0)), nil
+	case FormatRFC3339:
+		return t.Format(time.RFC3339Nano), nil
+	case FormatUnix:
+		return strconv.FormatInt(t.Unix(), 10), nil
+	case FormatUnixNano:
+		return strconv.FormatInt(t.UnixNano(), 10), nil
+	case FormatLayout:
+		if o.Layout == "" {
+			return "", errors.New("layout format requires a layout")
+		}
+		return t.Format(o.Layout), nil
+	case FormatJSON:
+		v := timeJSON{
+			Time:     t.Format(time.RFC3339Nano),
+			Unix:     t.Unix(),
+			UnixNano: t.UnixNano(),
+		}
+		if res != nil {
+			v.Server, v.Offset = res.Server, res.Offset
+			v.RTT, v.Stratum = res.Response.RTT, res.Response.Stratum
+		}
+		b, err := json.Marshal(v)
+		return string(b), err
+	default:
+		return "", fmt.Errorf("unknown output format: %q", o.Format)
+	}
+}
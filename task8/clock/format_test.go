@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+func TestOutputFormat(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("tzdata is not available:", err)
+	}
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	res := &Result{Offset: 1500 * time.Microsecond, Server: "pool.ntp.org", Response: &ntp.Response{Stratum: 2}}
+
+	tests := []struct {
+		name    string
+		out     Output
+		want    string
+		wantErr bool
+	}{
+		{name: "default", out: Output{}, want: "Current time: 2024-03-01 12:30:45.123456789 +0000 UTC"},
+		{name: "rfc3339", out: Output{Format: FormatRFC3339}, want: "2024-03-01T12:30:45.123456789Z"},
+		{name: "rfc3339 moscow", out: Output{Format: FormatRFC3339, Location: moscow}, want: "2024-03-01T15:30:45.123456789+03:00"},
+		{name: "unix", out: Output{Format: FormatUnix}, want: "1709296245"},
+		{name: "unixnano", out: Output{Format: FormatUnixNano}, want: "1709296245123456789"},
+		{name: "layout", out: Output{Format: FormatLayout, Layout: "02.01.2006 15:04"}, want: "01.03.2024 12:30"},
+		{name: "layout without layout", out: Output{Format: FormatLayout}, wantErr: true},
+		{name: "unknown", out: Output{Format: "xml"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.out.Render(ts, res)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+
+	got, err := Output{Format: FormatJSON, Location: time.UTC}.Render(ts, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal([]byte(got), &v); err != nil {
+		t.Fatalf("invalid json %q: %v", got, err)
+	}
+	if v["server"] != "pool.ntp.org" || v["offset_ns"] != 1.5e6 || !strings.HasPrefix(v["time"].(string), "2024-03-01T12:30:45") {
+		t.Errorf("unexpected json: %s", got)
+	}
+}
@@ -0,0 +1,396 @@
+package clock
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ошибки NTS. Их можно проверить через errors.Is; запрос без защиты в этих
+// случаях не выполняется.
+var (
+	ErrNTSKeyExchange = errors.New("nts key exchange failed")
+	ErrNTSAuth        = errors.New("nts authentication failed")
+)
+
+const (
+	ntsKEPort     = 4460
+	ntsALPN       = "ntske/1"
+	ntsExporter   = "EXPORTER-network-time-security"
+	ntsProtoNTPv4 = 0
+	ntsAEADSIV256 = 15 // AEAD_AES_SIV_CMAC_256
+	ntsKeySize    = 32
+
+	// Типы записей NTS-KE (RFC 8915, 4.1)
+	recEnd       = 0
+	recNextProto = 1
+	recError     = 2
+	recWarning   = 3
+	recAEAD      = 4
+	recCookie    = 5
+	recServer    = 6
+	recPort      = 7
+	recCritical  = 0x8000
+
+	// Типы полей расширения NTP (RFC 8915, 5.7)
+	efUniqueID      = 0x0104
+	efCookie        = 0x0204
+	efAuthenticator = 0x0404
+)
+
+// ntsSession содержит результат NTS-KE: ключи и cookies для запросов NTP.
+type ntsSession struct {
+	address string // адрес NTP-сервера host:port
+	c2s     *aesSIV
+	s2c     *aesSIV
+
+	mu      sync.Mutex
+	cookies [][]byte
+}
+
+// popCookie извлекает очередной cookie; каждый cookie используется один раз.
+func (s *ntsSession) popCookie() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cookies) == 0 {
+		return nil, false
+	}
+	c := s.cookies[0]
+	s.cookies = s.cookies[1:]
+	return c, true
+}
+
+// pushCookies сохраняет cookies, выданные сервером в ответе.
+func (s *ntsSession) pushCookies(cookies [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cookies = append(s.cookies, cookies...)
+}
+
+// ntsSessions кеширует сессии по адресу NTS-KE, чтобы не выполнять TLS-рукопожатие
+// при каждом опросе, пока у сессии остаются cookies.
+var ntsSessions = struct {
+	sync.Mutex
+	m map[string]*ntsSession
+}{m: make(map[string]*ntsSession)}
+
+// queryNTS выполняет защищённый NTS запрос к серверу server, при необходимости
+// проводя NTS-KE.
+func queryNTS(server string, opts Options) (*ntsExtension, string, error) {
+	keAddr, host, err := ntsKEAddress(server)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNTSKeyExchange, err)
+	}
+
+	ntsSessions.Lock()
+	session := ntsSessions.m[keAddr]
+	ntsSessions.Unlock()
+
+	cookie, ok := []byte(nil), false
+	if session != nil {
+		cookie, ok = session.popCookie()
+	}
+	if !ok {
+		session, err = ntsKeyExchange(keAddr, host, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		ntsSessions.Lock()
+		ntsSessions.m[keAddr] = session
+		ntsSessions.Unlock()
+		cookie, _ = session.popCookie()
+	}
+	return &ntsExtension{session: session, cookie: cookie}, session.address, nil
+}
+
+// forgetNTS удаляет сессию из кеша, например после ошибки аутентификации.
+func forgetNTS(server string) {
+	keAddr, _, err := ntsKEAddress(server)
+	if err != nil {
+		return
+	}
+	ntsSessions.Lock()
+	delete(ntsSessions.m, keAddr)
+	ntsSessions.Unlock()
+}
+
+// ntsKEAddress возвращает адрес NTS-KE (порт 4460 по умолчанию) и имя хоста для TLS.
+func ntsKEAddress(server string) (string, string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return net.JoinHostPort(server, strconv.Itoa(ntsKEPort)), server, nil
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid server address %q", server)
+	}
+	return net.JoinHostPort(host, port), host, nil
+}
+
+// ntsKeyExchange выполняет NTS-KE (RFC 8915, раздел 4) поверх TLS 1.3.
+func ntsKeyExchange(keAddr, host string, opts Options) (*ntsSession, error) {
+	cfg := &tls.Config{}
+	if opts.TLSConfig != nil {
+		cfg = opts.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	cfg.MinVersion = tls.VersionTLS13
+	cfg.NextProtos = []string{ntsALPN}
+
+	dialer := &net.Dialer{Timeout: opts.timeout()}
+	conn, err := tls.DialWithDialer(dialer, "tcp", keAddr, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNTSKeyExchange, keAddr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(opts.timeout()))
+
+	state := conn.ConnectionState()
+	if state.NegotiatedProtocol != ntsALPN {
+		return nil, fmt.Errorf("%w: %s: server did not negotiate %s", ErrNTSKeyExchange, keAddr, ntsALPN)
+	}
+
+	var req bytes.Buffer
+	writeRecord(&req, recCritical|recNextProto, uint16Body(ntsProtoNTPv4))
+	writeRecord(&req, recCritical|recAEAD, uint16Body(ntsAEADSIV256))
+	writeRecord(&req, recCritical|recEnd, nil)
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNTSKeyExchange, keAddr, err)
+	}
+
+	session := &ntsSession{}
+	ntpHost, ntpPort := host, opts.ntpPort()
+	var gotProto, gotAEAD bool
+	for {
+		typ, body, err := readRecord(conn)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrNTSKeyExchange, keAddr, err)
+		}
+		critical := typ&recCritical != 0
+		switch typ &^ recCritical {
+		case recEnd:
+			if !gotProto || !gotAEAD {
+				return nil, fmt.Errorf("%w: %s: server did not agree on NTPv4 with AES-SIV-CMAC-256", ErrNTSKeyExchange, keAddr)
+			}
+			if len(session.cookies) == 0 {
+				return nil, fmt.Errorf("%w: %s: server sent no cookies", ErrNTSKeyExchange, keAddr)
+			}
+			session.address = net.JoinHostPort(ntpHost, strconv.Itoa(ntpPort))
+			return session, deriveNTSKeys(&state, session)
+		case recNextProto:
+			gotProto = len(body) == 2 && binary.BigEndian.Uint16(body) == ntsProtoNTPv4
+		case recAEAD:
+			gotAEAD = len(body) == 2 && binary.BigEndian.Uint16(body) == ntsAEADSIV256
+		case recCookie:
+			session.cookies = append(session.cookies, body)
+		case recServer:
+			ntpHost = string(body)
+		case recPort:
+			if len(body) != 2 {
+				return nil, fmt.Errorf("%w: %s: malformed port record", ErrNTSKeyExchange, keAddr)
+			}
+			ntpPort = int(binary.BigEndian.Uint16(body))
+		case recError:
+			return nil, fmt.Errorf("%w: %s: server error %s", ErrNTSKeyExchange, keAddr, recordCode(body))
+		case recWarning:
+			return nil, fmt.Errorf("%w: %s: server warning %s", ErrNTSKeyExchange, keAddr, recordCode(body))
+		default:
+			if critical {
+				return nil, fmt.Errorf("%w: %s: unknown critical record %d", ErrNTSKeyExchange, keAddr, typ&^recCritical)
+			}
+		}
+	}
+}
+
+// deriveNTSKeys извлекает ключи C2S и S2C через TLS exporter (RFC 8915, 4.3).
+func deriveNTSKeys(state *tls.ConnectionState, session *ntsSession) error {
+	keys := make([]*aesSIV, 2)
+	for i := range keys {
+		context := []byte{0, ntsProtoNTPv4, 0, ntsAEADSIV256, byte(i)}
+		key, err := state.ExportKeyingMaterial(ntsExporter, context, ntsKeySize)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrNTSKeyExchange, err)
+		}
+		if keys[i], err = newAESSIV(key); err != nil {
+			return fmt.Errorf("%w: %v", ErrNTSKeyExchange, err)
+		}
+	}
+	session.c2s, session.s2c = keys[0], keys[1]
+	return nil
+}
+
+// recordCode форматирует код из тела записи Error или Warning.
+func recordCode(body []byte) string {
+	if len(body) != 2 {
+		return "(malformed)"
+	}
+	return strconv.Itoa(int(binary.BigEndian.Uint16(body)))
+}
+
+func uint16Body(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+// writeRecord добавляет запись NTS-KE в буфер.
+func writeRecord(buf *bytes.Buffer, typ uint16, body []byte) {
+	var hdr [4]byte
+	binary.BigEndian.PutUint16(hdr[0:], typ)
+	binary.BigEndian.PutUint16(hdr[2:], uint16(len(body)))
+	buf.Write(hdr[:])
+	buf.Write(body)
+}
+
+// readRecord читает одну запись NTS-KE.
+func readRecord(r io.Reader) (uint16, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(hdr[2:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint16(hdr[0:]), body, nil
+}
+
+// appendEF добавляет поле расширения NTP, выравнивая его до 4 байт.
+func appendEF(buf *bytes.Buffer, typ uint16, body []byte) {
+	padded := (len(body) + 3) &^ 3
+	var hdr [4]byte
+	binary.BigEndian.PutUint16(hdr[0:], typ)
+	binary.BigEndian.PutUint16(hdr[2:], uint16(4+padded))
+	buf.Write(hdr[:])
+	buf.Write(body)
+	buf.Write(make([]byte, padded-len(body)))
+}
+
+// extField — поле расширения NTP и его смещение в пакете.
+type extField struct {
+	typ    uint16
+	body   []byte
+	offset int
+}
+
+// parseEFs разбирает поля расширения, начиная со смещения start.
+func parseEFs(packet []byte, start int) ([]extField, error) {
+	var fields []extField
+	for off := start; off < len(packet); {
+		if len(packet)-off < 4 {
+			return nil, errors.New("truncated extension field")
+		}
+		typ := binary.BigEndian.Uint16(packet[off:])
+		length := int(binary.BigEndian.Uint16(packet[off+2:]))
+		if length < 4 || length%4 != 0 || off+length > len(packet) {
+			return nil, errors.New("malformed extension field")
+		}
+		fields = append(fields, extField{typ: typ, body: packet[off+4 : off+length], offset: off})
+		off += length
+	}
+	return fields, nil
+}
+
+// sealAuthenticator добавляет поле NTS Authenticator, защищающее всё
+// содержимое buf, с зашифрованными полями plaintext.
+func sealAuthenticator(buf *bytes.Buffer, aead *aesSIV, plaintext []byte) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ciphertext := aead.Seal(plaintext, buf.Bytes(), nonce)
+
+	var body bytes.Buffer
+	binary.Write(&body, binary.BigEndian, uint16(len(nonce)))
+	binary.Write(&body, binary.BigEndian, uint16(len(ciphertext)))
+	body.Write(nonce)
+	body.Write(ciphertext)
+	body.Write(make([]byte, (4-len(ciphertext)%4)%4))
+	appendEF(buf, efAuthenticator, body.Bytes())
+	return nil
+}
+
+// openAuthenticator проверяет поле NTS Authenticator и возвращает
+// расшифрованные поля расширения.
+func openAuthenticator(packet []byte, ef extField, aead *aesSIV) ([]byte, error) {
+	if len(ef.body) < 4 {
+		return nil, errors.New("malformed authenticator")
+	}
+	nonceLen := int(binary.BigEndian.Uint16(ef.body[0:]))
+	ctLen := int(binary.BigEndian.Uint16(ef.body[2:]))
+	paddedNonce := (nonceLen + 3) &^ 3
+	if 4+paddedNonce+ctLen > len(ef.body) {
+		return nil, errors.New("malformed authenticator")
+	}
+	nonce := ef.body[4 : 4+nonceLen]
+	ciphertext := ef.body[4+paddedNonce : 4+paddedNonce+ctLen]
+	return aead.Open(ciphertext, packet[:ef.offset], nonce)
+}
+
+// ntsExtension добавляет к запросу NTP поля NTS и проверяет ответ сервера.
+type ntsExtension struct {
+	session *ntsSession
+	cookie  []byte
+	uid     []byte
+}
+
+// ProcessQuery добавляет Unique Identifier, cookie и аутентификатор.
+func (e *ntsExtension) ProcessQuery(buf *bytes.Buffer) error {
+	e.uid = make([]byte, 32)
+	if _, err := rand.Read(e.uid); err != nil {
+		return err
+	}
+	appendEF(buf, efUniqueID, e.uid)
+	appendEF(buf, efCookie, e.cookie)
+	return sealAuthenticator(buf, e.session.c2s, nil)
+}
+
+// ProcessResponse проверяет подлинность ответа и сохраняет новые cookies.
+func (e *ntsExtension) ProcessResponse(buf []byte) error {
+	if len(buf) < packetSize {
+		return fmt.Errorf("%w: short response", ErrNTSAuth)
+	}
+	if buf[1] == 0 && string(buf[12:16]) == "NTSN" {
+		return fmt.Errorf("%w: server rejected the cookie (NTSN)", ErrNTSAuth)
+	}
+	fields, err := parseEFs(buf, packetSize)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNTSAuth, err)
+	}
+
+	var uidOK bool
+	for _, ef := range fields {
+		switch ef.typ {
+		case efUniqueID:
+			uidOK = bytes.Equal(ef.body, e.uid)
+		case efAuthenticator:
+			if !uidOK {
+				return fmt.Errorf("%w: unique identifier mismatch", ErrNTSAuth)
+			}
+			plaintext, err := openAuthenticator(buf, ef, e.session.s2c)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrNTSAuth, err)
+			}
+			inner, err := parseEFs(plaintext, 0)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrNTSAuth, err)
+			}
+			var cookies [][]byte
+			for _, c := range inner {
+				if c.typ == efCookie {
+					cookies = append(cookies, append([]byte(nil), c.body...))
+				}
+			}
+			e.session.pushCookies(cookies)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: response is not authenticated", ErrNTSAuth)
+}
@@ -0,0 +1,360 @@
+package clock
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// ntsStandIn — NTS-KE и NTS-NTP сервер для тестов.
+type ntsStandIn struct {
+	t       *testing.T
+	srv     *Server
+	keAddr  string
+	udp     net.PacketConn
+	tamper  atomic.Bool
+	keCount atomic.Int32
+
+	mu      sync.Mutex
+	cookies map[string][2]*aesSIV // cookie -> {c2s, s2c}
+}
+
+// newTLSPair создаёт самоподписанный сертификат для localhost и клиентскую
+// конфигурацию, которая ему доверяет.
+func newTLSPair(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		NextProtos:   []string{ntsALPN},
+		MinVersion:   tls.VersionTLS13,
+	}
+	return server, &tls.Config{RootCAs: pool}
+}
+
+func startNTSStandIn(t *testing.T, serverTLS *tls.Config) *ntsStandIn {
+	t.Helper()
+	srv, err := NewServer(ServerOptions{Stratum: 1, RefID: "NTS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ke, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ntsStandIn{t: t, srv: srv, keAddr: ke.Addr().String(), udp: udp, cookies: make(map[string][2]*aesSIV)}
+	t.Cleanup(func() {
+		ke.Close()
+		udp.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := ke.Accept()
+			if err != nil {
+				return
+			}
+			go s.keyExchange(conn.(*tls.Conn))
+		}
+	}()
+	go s.serveNTP()
+	return s
+}
+
+func (s *ntsStandIn) newCookie(c2s, s2c *aesSIV) []byte {
+	cookie := make([]byte, 16)
+	rand.Read(cookie)
+	s.mu.Lock()
+	s.cookies[string(cookie)] = [2]*aesSIV{c2s, s2c}
+	s.mu.Unlock()
+	return cookie
+}
+
+func (s *ntsStandIn) keyExchange(conn *tls.Conn) {
+	defer conn.Close()
+	s.keCount.Add(1)
+	for {
+		typ, _, err := readRecord(conn)
+		if err != nil {
+			return
+		}
+		if typ&^recCritical == recEnd {
+			break
+		}
+	}
+
+	state := conn.ConnectionState()
+	session := &ntsSession{}
+	if err := deriveNTSKeys(&state, session); err != nil {
+		s.t.Error(err)
+		return
+	}
+
+	var resp bytes.Buffer
+	writeRecord(&resp, recCritical|recNextProto, uint16Body(ntsProtoNTPv4))
+	writeRecord(&resp, recAEAD, uint16Body(ntsAEADSIV256))
+	writeRecord(&resp, recServer, []byte("127.0.0.1"))
+	writeRecord(&resp, recPort, uint16Body(uint16(s.udp.LocalAddr().(*net.UDPAddr).Port)))
+	for i := 0; i < 2; i++ {
+		writeRecord(&resp, recCookie, s.newCookie(session.c2s, session.s2c))
+	}
+	writeRecord(&resp, recCritical|recEnd, nil)
+	conn.Write(resp.Bytes())
+}
+
+func (s *ntsStandIn) serveNTP() {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.respond(buf[:n]); resp != nil {
+			s.udp.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *ntsStandIn) respond(req []byte) []byte {
+	hdr, ok := s.srv.respond(req, time.Now())
+	if !ok {
+		return nil
+	}
+	fields, err := parseEFs(req, packetSize)
+	if err != nil {
+		return nil
+	}
+
+	var uid []byte
+	var keys [2]*aesSIV
+	for _, ef := range fields {
+		switch ef.typ {
+		case efUniqueID:
+			uid = ef.body
+		case efCookie:
+			s.mu.Lock()
+			keys, ok = s.cookies[string(ef.body)]
+			delete(s.cookies, string(ef.body))
+			s.mu.Unlock()
+			if !ok {
+				return nil
+			}
+		case efAuthenticator:
+			if keys[0] == nil {
+				return nil
+			}
+			if _, err := openAuthenticator(req, ef, keys[0]); err != nil {
+				return nil
+			}
+		}
+	}
+
+	var out, inner bytes.Buffer
+	out.Write(hdr)
+	appendEF(&out, efUniqueID, uid)
+	appendEF(&inner, efCookie, s.newCookie(keys[0], keys[1]))
+	if err := sealAuthenticator(&out, keys[1], inner.Bytes()); err != nil {
+		return nil
+	}
+	resp := out.Bytes()
+	if s.tamper.Load() {
+		resp[40] ^= 0xff // подмена transmit timestamp
+	}
+	return resp
+}
+
+func TestNTSQuery(t *testing.T) {
+	serverTLS, clientTLS := newTLSPair(t)
+	standIn := startNTSStandIn(t, serverTLS)
+
+	opts := Options{
+		Servers:   []string{standIn.keAddr},
+		Timeout:   time.Second,
+		NTS:       true,
+		TLSConfig: clientTLS,
+	}
+	for i := 0; i < 3; i++ {
+		res, err := Query(opts)
+		if err != nil {
+			t.Fatalf("query %d: expected nil, got error: %v", i, err)
+		}
+		if res.Response.ReferenceString() != ".NTS." {
+			t.Errorf("expected reference .NTS., got %s", res.Response.ReferenceString())
+		}
+	}
+	// Сервер выдаёт новый cookie в каждом ответе, поэтому NTS-KE выполняется один раз
+	if got := standIn.keCount.Load(); got != 1 {
+		t.Errorf("expected 1 key exchange, got %d", got)
+	}
+
+	standIn.tamper.Store(true)
+	_, err := Query(opts)
+	if !errors.Is(err, ErrNTSAuth) {
+		t.Errorf("expected ErrNTSAuth, got %v", err)
+	}
+}
+
+func TestNTSUntrustedCertificate(t *testing.T) {
+	serverTLS, _ := newTLSPair(t)
+	standIn := startNTSStandIn(t, serverTLS)
+
+	opts := Options{Servers: []string{standIn.keAddr}, Timeout: time.Second, NTS: true}
+	_, err := Query(opts)
+	if !errors.Is(err, ErrNTSKeyExchange) {
+		t.Errorf("expected ErrNTSKeyExchange, got %v", err)
+	}
+}
+
+// startSymmetricServer запускает сервер, подписывающий ответы ключом MD5.
+func startSymmetricServer(t *testing.T, keyID uint32, key string) *net.UDPAddr {
+	t.Helper()
+	srv, err := NewServer(ServerOptions{Stratum: 2, RefID: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp, ok := srv.respond(buf[:n], time.Now())
+			if !ok {
+				continue
+			}
+			digest := md5.Sum(append([]byte(key), resp...))
+			resp = binary.BigEndian.AppendUint32(resp, keyID)
+			conn.WriteTo(append(resp, digest[:]...), addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestSymmetricKeyAuth(t *testing.T) {
+	addr := startSymmetricServer(t, 7, "secret")
+
+	tests := []struct {
+		name    string
+		auth    ntp.AuthOptions
+		wantErr bool
+	}{
+		{name: "valid key", auth: ntp.AuthOptions{Type: ntp.AuthMD5, KeyID: 7, Key: "secret"}},
+		{name: "wrong key", auth: ntp.AuthOptions{Type: ntp.AuthMD5, KeyID: 7, Key: "wrong"}, wantErr: true},
+		{name: "wrong key id", auth: ntp.AuthOptions{Type: ntp.AuthMD5, KeyID: 8, Key: "secret"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := Options{Servers: []string{addr.String()}, Timeout: time.Second, Auth: test.auth}
+			_, err := Query(opts)
+			if test.wantErr {
+				if !errors.Is(err, ntp.ErrAuthFailed) {
+					t.Errorf("expected ErrAuthFailed, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("expected nil, got error: %v", err)
+			}
+		})
+	}
+
+	// Сервер без подписи не должен приниматься при включённой аутентификации
+	plain := startFakeServer(t, 0)
+	opts := Options{Servers: []string{plain.String()}, Timeout: time.Second, Auth: tests[0].auth}
+	if _, err := Query(opts); !errors.Is(err, ntp.ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for unsigned response, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(bytes.NewBufferString("# ntp.keys\n1 MD5 secret\n2 SHA1 6931564b4a5a5045766c55356b30656c7666316c\n"))
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if keys[1].Type != ntp.AuthMD5 || keys[1].Key != "secret" || keys[2].Type != ntp.AuthSHA1 {
+		t.Errorf("unexpected keys: %+v", keys)
+	}
+	if _, err := ParseKeys(bytes.NewBufferString("1 RC4 secret\n")); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestKeyIDFlag(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    KeyID
+		wantErr bool
+	}{
+		{arg: "1", want: 1},
+		{arg: "65535", want: 65535},
+		{arg: "65536", wantErr: true},
+		{arg: "65543", wantErr: true}, // обрезалось бы до ключа 7
+		{arg: "-1", wantErr: true},
+		{arg: "md5", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.arg, func(t *testing.T) {
+			var id KeyID
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.Var(&id, "keyid", "")
+			err := fs.Parse([]string{"-keyid=" + test.arg})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id != test.want {
+				t.Errorf("expected %d, got %d", test.want, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package clock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// reportFormat — шаблон отчёта о смещении локальных часов
+const reportFormat = `Server:       %s
+Offset:       %s
+Round-trip:   %s
+Stratum:      %d
+Reference ID: %s
+Leap:         %s
+`
+
+// PrintReport выводит смещение локальных часов и параметры лучшего сервера.
+func PrintReport(w io.Writer, res *Result) error {
+	r := res.Response
+	_, err := fmt.Fprintf(w, reportFormat,
+		res.Server, res.Offset, r.RTT, r.Stratum, r.ReferenceString(), leapString(r.Leap))
+	return err
+}
+
+// leapString возвращает текстовое описание индикатора високосной секунды.
+func leapString(leap ntp.LeapIndicator) string {
+	switch leap {
+	case ntp.LeapNoWarning:
+		return "none"
+	case ntp.LeapAddSecond:
+		return "insert second"
+	case ntp.LeapDelSecond:
+		return "delete second"
+	default:
+		return "not synchronized"
+	}
+}
+
+// DriftRecord — одно измерение в режиме мониторинга дрейфа часов.
+type DriftRecord struct {
+	Time    time.Time     `json:"time"`
+	Server  string        `json:"server,omitempty"`
+	Offset  time.Duration `json:"offset_ns"`
+	RTT     time.Duration `json:"rtt_ns"`
+	Drift   time.Duration `json:"drift_ns"`  // изменение смещения с первого измерения
+	PPM     float64       `json:"drift_ppm"` // скорость дрейфа в миллионных долях
+	Stratum uint8         `json:"stratum"`
+	Err     string        `json:"error,omitempty"`
+}
+
+// DriftWriter выводит записи мониторинга дрейфа.
+type DriftWriter interface {
+	Write(rec DriftRecord) error
+}
+
+// NewDriftWriter создаёт DriftWriter для формата "table" или "json".
+func NewDriftWriter(w io.Writer, format string) (DriftWriter, error) {
+	switch format {
+	case "table", "":
+		return &tableWriter{w: w}, nil
+	case "json":
+		return &jsonWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown drift format: %q", format)
+	}
+}
+
+// tableWriter выводит записи в виде выровненной таблицы.
+type tableWriter struct {
+	w      io.Writer
+	header bool
+}
+
+func (t *tableWriter) Write(rec DriftRecord) error {
+	if !t.header {
+		t.header = true
+		_, err := fmt.Fprintf(t.w, "%-25s  %-24s  %14s  %12s  %14s  %10s\n",
+			"TIME", "SERVER", "OFFSET", "RTT", "DRIFT", "PPM")
+		if err != nil {
+			return err
+		}
+	}
+	ts := rec.Time.Format(time.RFC3339)
+	if rec.Err != "" {
+		_, err := fmt.Fprintf(t.w, "%-25s  error: %s\n", ts, rec.Err)
+		return err
+	}
+	_, err := fmt.Fprintf(t.w, "%-25s  %-24s  %14s  %12s  %14s  %10.3f\n",
+		ts, rec.Server, rec.Offset, rec.RTT, rec.Drift, rec.PPM)
+	return err
+}
+
+// jsonWriter выводит записи в формате JSON Lines.
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonWriter) Write(rec DriftRecord) error {
+	return j.enc.Encode(rec)
+}
+
+// Watch опрашивает серверы с интервалом interval и выводит изменение смещения
+// локальных часов. Ошибки отдельных опросов записываются и не прерывают
+// мониторинг. Если count > 0, выполняется не более count опросов.
+func Watch(ctx context.Context, opts Options, interval time.Duration, count int, out DriftWriter) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		started     bool
+		firstTime   time.Time
+		firstOffset time.Duration
+	)
+	for i := 0; count <= 0 || i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+
+		rec := DriftRecord{Time: time.Now()}
+		res, err := Query(opts)
+		if err != nil {
+			rec.Err = err.Error()
+		} else {
+			rec.Server = res.Server
+			rec.Offset = res.Offset
+			rec.RTT = res.Response.RTT
+			rec.Stratum = res.Response.Stratum
+			if !started {
+				started, firstTime, firstOffset = true, rec.Time, rec.Offset
+			}
+			rec.Drift = rec.Offset - firstOffset
+			if elapsed := rec.Time.Sub(firstTime); elapsed > 0 {
+				rec.PPM = float64(rec.Drift) / float64(elapsed) * 1e6
+			}
+		}
+		if err := out.Write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package clock
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// maxSurvivors — сколько лучших по root distance серверов участвуют в усреднении
+const maxSurvivors = 3
+
+// sample содержит ответ одного NTP-сервера
+type sample struct {
+	server   string
+	response *ntp.Response
+}
+
+// combineOffset отбрасывает фальшивые часы, выбирает лучшие ответы и
+// возвращает их взвешенное смещение локальных часов вместе с лучшим ответом.
+func combineOffset(samples []sample) (time.Duration, sample, error) {
+	survivors := selectTruechimers(samples)
+	if len(survivors) == 0 {
+		return 0, sample{}, errors.New("no majority of ntp-servers agree on the current time")
+	}
+
+	// Предпочитаем серверы с меньшим root distance, а при равенстве — с меньшим RTT
+	sort.Slice(survivors, func(i, j int) bool {
+		a, b := survivors[i].response, survivors[j].response
+		if a.RootDistance != b.RootDistance {
+			return a.RootDistance < b.RootDistance
+		}
+		return a.RTT < b.RTT
+	})
+	if len(survivors) > maxSurvivors {
+		survivors = survivors[:maxSurvivors]
+	}
+
+	// Усредняем смещения с весом, обратным root distance (RFC 5905, A.5.5.5)
+	var sum, weights float64
+	for _, s := range survivors {
+		w := 1 / math.Max(s.response.RootDistance.Seconds(), 1e-6)
+		sum += w * float64(s.response.ClockOffset)
+		weights += w
+	}
+	return time.Duration(sum / weights), survivors[0], nil
+}
+
+// endpoint — граница интервала корректности для алгоритма пересечения
+type endpoint struct {
+	value time.Duration
+	kind  int // +1 нижняя граница, 0 середина, -1 верхняя граница
+}
+
+// selectTruechimers реализует алгоритм пересечения из RFC 5905 (A.5.5.1):
+// находит интервал, с которым согласно большинство серверов, и отбрасывает
+// серверы, чьи интервалы корректности его не пересекают.
+func selectTruechimers(samples []sample) []sample {
+	n := len(samples)
+	points := make([]endpoint, 0, 3*n)
+	for _, s := range samples {
+		off, dist := s.response.ClockOffset, s.response.RootDistance
+		points = append(points,
+			endpoint{value: off - dist, kind: +1},
+			endpoint{value: off, kind: 0},
+			endpoint{value: off + dist, kind: -1},
+		)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].value != points[j].value {
+			return points[i].value < points[j].value
+		}
+		return points[i].kind > points[j].kind
+	})
+
+	for allow := 0; 2*allow < n; allow++ {
+		var low, high time.Duration
+		var lowOK, highOK bool
+		found, chime := 0, 0
+		for _, p := range points {
+			chime += p.kind
+			if chime >= n-allow {
+				low, lowOK = p.value, true
+				break
+			}
+			if p.kind == 0 {
+				found++
+			}
+		}
+
+		chime = 0
+		for i := len(points) - 1; i >= 0; i-- {
+			p := points[i]
+			chime -= p.kind
+			if chime >= n-allow {
+				high, highOK = p.value, true
+				break
+			}
+			if p.kind == 0 {
+				found++
+			}
+		}
+
+		if !lowOK || !highOK || found > allow || low > high {
+			continue
+		}
+
+		survivors := make([]sample, 0, n)
+		for _, s := range samples {
+			off, dist := s.response.ClockOffset, s.response.RootDistance
+			if off+dist >= low && off-dist <= high {
+				survivors = append(survivors, s)
+			}
+		}
+		return survivors
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+func newSample(server string, offset, dist, rtt time.Duration) sample {
+	return sample{
+		server: server,
+		response: &ntp.Response{
+			ClockOffset:  offset,
+			RootDistance: dist,
+			RTT:          rtt,
+		},
+	}
+}
+
+func TestSelectTruechimers(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []sample
+		want    []string
+	}{
+		{
+			name: "all agree",
+			samples: []sample{
+				newSample("a", 10*time.Millisecond, 20*time.Millisecond, 0),
+				newSample("b", 12*time.Millisecond, 20*time.Millisecond, 0),
+				newSample("c", 8*time.Millisecond, 20*time.Millisecond, 0),
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "one falseticker",
+			samples: []sample{
+				newSample("a", 10*time.Millisecond, 20*time.Millisecond, 0),
+				newSample("b", 12*time.Millisecond, 20*time.Millisecond, 0),
+				newSample("bad", 5*time.Second, 20*time.Millisecond, 0),
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "no majority",
+			samples: []sample{
+				newSample("a", 0, 10*time.Millisecond, 0),
+				newSample("b", time.Second, 10*time.Millisecond, 0),
+			},
+			want: nil,
+		},
+		{
+			name: "single server",
+			samples: []sample{
+				newSample("a", time.Second, 10*time.Millisecond, 0),
+			},
+			want: []string{"a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := selectTruechimers(test.samples)
+			if len(got) != len(test.want) {
+				t.Fatalf("expected %d survivors, got %d", len(test.want), len(got))
+			}
+			for i, s := range got {
+				if s.server != test.want[i] {
+					t.Errorf("expected %s, got %s", test.want[i], s.server)
+				}
+			}
+		})
+	}
+}
+
+func TestCombineOffset(t *testing.T) {
+	samples := []sample{
+		newSample("near", 10*time.Millisecond, 10*time.Millisecond, time.Millisecond),
+		newSample("far", 20*time.Millisecond, 40*time.Millisecond, 50*time.Millisecond),
+		newSample("bad", 10*time.Second, 10*time.Millisecond, time.Millisecond),
+	}
+	got, best, err := combineOffset(samples)
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if best.server != "near" {
+		t.Errorf("expected best server near, got %s", best.server)
+	}
+	// Веса 1/0.01 и 1/0.04: (100*10 + 25*20) / 125 = 12ms
+	if want := 12 * time.Millisecond; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, _, err := combineOffset(nil); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,277 @@
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	packetSize     = 48         // размер заголовка NTP без расширений
+	ntpEpochOffset = 2208988800 // секунд между 1900-01-01 и 1970-01-01
+	modeClient     = 3
+	modeServer     = 4
+	leapNotInSync  = 3
+	serverPoll     = 6    // 64 секунды
+	serverPrec     = 0xec // -20 в дополнительном коде, ~1 мкс
+	maxStratum     = 15
+	unsyncedStrat  = 16
+)
+
+// SourceInfo описывает состояние синхронизации источника времени сервера.
+type SourceInfo struct {
+	Synced         bool          // false — клиенты получат LI=3 (часы не синхронизированы)
+	RefTime        time.Time     // время последней синхронизации
+	RootDelay      time.Duration // задержка до эталонных часов
+	RootDispersion time.Duration // накопленная погрешность относительно эталона
+}
+
+// TimeSource — источник времени для SNTP-сервера.
+type TimeSource interface {
+	Now() (time.Time, SourceInfo)
+}
+
+// LocalClock отдаёт время локальных часов, сдвинутое на Offset.
+type LocalClock struct {
+	Offset time.Duration
+}
+
+// Now возвращает время локальных часов.
+func (c LocalClock) Now() (time.Time, SourceInfo) {
+	now := time.Now().Add(c.Offset)
+	return now, SourceInfo{Synced: true, RefTime: now}
+}
+
+// UpstreamClock отдаёт локальное время, скорректированное по смещению,
+// полученному от вышестоящих NTP-серверов через Query.
+type UpstreamClock struct {
+	opts Options
+
+	mu     sync.RWMutex
+	res    *Result
+	synced time.Time
+}
+
+// NewUpstreamClock создаёт источник времени, синхронизируемый с серверами из opts.
+func NewUpstreamClock(opts Options) *UpstreamClock {
+	return &UpstreamClock{opts: opts}
+}
+
+// Sync опрашивает вышестоящие серверы и обновляет смещение.
+func (c *UpstreamClock) Sync() error {
+	res, err := Query(c.opts)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.res = res
+	c.synced = time.Now().Add(res.Offset)
+	return nil
+}
+
+// Run периодически вызывает Sync до отмены ctx. При ошибке сохраняется
+// последнее успешное смещение, а ошибка передаётся в onError, если он задан.
+func (c *UpstreamClock) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Sync(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Stratum возвращает stratum вышестоящего сервера или 0, если синхронизации ещё не было.
+func (c *UpstreamClock) Stratum() uint8 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.res == nil {
+		return 0
+	}
+	return c.res.Response.Stratum
+}
+
+// Now возвращает скорректированное время и параметры последней синхронизации.
+func (c *UpstreamClock) Now() (time.Time, SourceInfo) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.res == nil {
+		return time.Now(), SourceInfo{}
+	}
+	r := c.res.Response
+	return time.Now().Add(c.res.Offset), SourceInfo{
+		Synced:         true,
+		RefTime:        c.synced,
+		RootDelay:      r.RootDelay + r.RTT,
+		RootDispersion: r.RootDispersion + r.MinError,
+	}
+}
+
+// ServerOptions содержит параметры SNTP-сервера.
+type ServerOptions struct {
+	Stratum uint8      // stratum сервера, 1..15
+	RefID   string     // reference ID: до 4 ASCII-символов или IPv4-адрес
+	Source  TimeSource // источник времени, по умолчанию LocalClock
+}
+
+// Server отвечает на SNTPv4-запросы (RFC 4330).
+type Server struct {
+	stratum uint8
+	refID   uint32
+	source  TimeSource
+}
+
+// ResolveStratum выбирает stratum сервера: requested, если он задан (не 0),
+// иначе upstream+1 при синхронизации с вышестоящим сервером stratum upstream
+// или 1 для локальных часов (upstream = 0). Stratum выше 15 отклоняется:
+// 16 означает, что часы не синхронизированы.
+func ResolveStratum(requested uint, upstream uint8) (uint8, error) {
+	switch {
+	case requested > maxStratum:
+		return 0, fmt.Errorf("invalid stratum %d: must be in 1..%d (%d means unsynchronized)",
+			requested, maxStratum, unsyncedStrat)
+	case requested > 0:
+		return uint8(requested), nil
+	case upstream >= maxStratum:
+		return 0, fmt.Errorf("upstream stratum %d is too high: serving at %d would mean unsynchronized, maximum is %d",
+			upstream, int(upstream)+1, maxStratum)
+	case upstream > 0:
+		return upstream + 1, nil
+	}
+	return 1, nil
+}
+
+// NewServer создаёт SNTP-сервер с указанными параметрами.
+func NewServer(opts ServerOptions) (*Server, error) {
+	if opts.Stratum < 1 || opts.Stratum > maxStratum {
+		return nil, fmt.Errorf("invalid stratum %d: must be in 1..%d", opts.Stratum, maxStratum)
+	}
+	refID, err := parseRefID(opts.RefID)
+	if err != nil {
+		return nil, err
+	}
+	source := opts.Source
+	if source == nil {
+		source = LocalClock{}
+	}
+	return &Server{stratum: opts.Stratum, refID: refID, source: source}, nil
+}
+
+// parseRefID переводит reference ID в 32-битное значение заголовка NTP.
+func parseRefID(s string) (uint32, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return binary.BigEndian.Uint32(ip4), nil
+		}
+		return 0, fmt.Errorf("invalid reference id %q: only IPv4 addresses are supported", s)
+	}
+	if len(s) > 4 {
+		return 0, fmt.Errorf("invalid reference id %q: longer than 4 characters", s)
+	}
+	var b [4]byte
+	for i := 0; i < len(s); i++ {
+		if s[i] < 32 || s[i] > 126 {
+			return 0, fmt.Errorf("invalid reference id %q: non-ASCII character", s)
+		}
+		b[i] = s[i]
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// ListenAndServe слушает UDP-адрес addr и обслуживает запросы до отмены ctx.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, conn)
+}
+
+// Serve обслуживает запросы на conn до отмены ctx и закрывает conn.
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			continue
+		}
+		recv, _ := s.source.Now()
+		resp, ok := s.respond(buf[:n], recv)
+		if !ok {
+			continue
+		}
+		conn.WriteTo(resp, addr)
+	}
+}
+
+// respond формирует ответ на клиентский запрос. Запросы другого режима,
+// а также слишком короткие пакеты игнорируются.
+func (s *Server) respond(req []byte, recv time.Time) ([]byte, bool) {
+	if len(req) < packetSize {
+		return nil, false
+	}
+	version := req[0] >> 3 & 0x07
+	mode := req[0] & 0x07
+	if mode != modeClient || version < 1 || version > 4 {
+		return nil, false
+	}
+
+	xmt, info := s.source.Now()
+	leap, stratum := byte(0), s.stratum
+	if !info.Synced {
+		leap, stratum = leapNotInSync, unsyncedStrat
+	}
+
+	resp := make([]byte, packetSize)
+	resp[0] = leap<<6 | version<<3 | modeServer
+	resp[1] = stratum
+	resp[2] = serverPoll
+	resp[3] = serverPrec
+	binary.BigEndian.PutUint32(resp[4:], toNTPShort(info.RootDelay))
+	binary.BigEndian.PutUint32(resp[8:], toNTPShort(info.RootDispersion))
+	binary.BigEndian.PutUint32(resp[12:], s.refID)
+	if !info.RefTime.IsZero() {
+		binary.BigEndian.PutUint64(resp[16:], toNTPTime(info.RefTime))
+	}
+	copy(resp[24:32], req[40:48]) // origin = transmit timestamp клиента
+	binary.BigEndian.PutUint64(resp[32:], toNTPTime(recv))
+	binary.BigEndian.PutUint64(resp[40:], toNTPTime(xmt))
+	return resp, true
+}
+
+// toNTPTime переводит время в 64-битный формат NTP (секунды с 1900 года).
+func toNTPTime(t time.Time) uint64 {
+	sec := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return sec<<32 | frac
+}
+
+// toNTPShort переводит длительность в 32-битный формат NTP 16.16.
+func toNTPShort(d time.Duration) uint32 {
+	if d < 0 {
+		return 0
+	}
+	sec := uint64(d / time.Second)
+	frac := uint64(d%time.Second) << 16 / uint64(time.Second)
+	return uint32(sec<<16 | frac)
+}
@@ -0,0 +1,128 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+func TestParseRefID(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    uint32
+		wantErr bool
+	}{
+		{name: "ascii", input: "LOCL", want: 0x4c4f434c},
+		{name: "short ascii", input: "GPS", want: 0x47505300},
+		{name: "ipv4", input: "192.168.0.1", want: 0xc0a80001},
+		{name: "empty", input: "", want: 0},
+		{name: "too long", input: "LOCAL", wantErr: true},
+		{name: "ipv6", input: "::1", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseRefID(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("expected %#x, got %#x", test.want, got)
+			}
+		})
+	}
+}
+
+func TestServerStratum(t *testing.T) {
+	for _, stratum := range []uint8{0, 16} {
+		if _, err := NewServer(ServerOptions{Stratum: stratum}); err == nil {
+			t.Errorf("stratum %d: expected error, got nil", stratum)
+		}
+	}
+}
+
+func TestResolveStratum(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested uint
+		upstream  uint8
+		want      uint8
+		wantErr   bool
+	}{
+		{name: "local default", want: 1},
+		{name: "explicit", requested: 5, want: 5},
+		{name: "explicit max", requested: 15, want: 15},
+		{name: "explicit unsynchronized", requested: 16, wantErr: true},
+		{name: "explicit wraps uint8", requested: 257, wantErr: true},
+		{name: "upstream", upstream: 2, want: 3},
+		{name: "explicit overrides upstream", requested: 4, upstream: 2, want: 4},
+		{name: "upstream 14", upstream: 14, want: 15},
+		{name: "upstream 15", upstream: 15, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ResolveStratum(test.requested, test.upstream)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("expected %d, got %d", test.want, got)
+			}
+		})
+	}
+}
+
+func TestServerResponse(t *testing.T) {
+	srv, err := NewServer(ServerOptions{Stratum: 1, RefID: "LOCL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startServer(t, srv)
+
+	resp, err := ntp.QueryWithOptions(addr.String(), ntp.QueryOptions{Timeout: time.Second, Version: 3})
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if err := resp.Validate(); err != nil {
+		t.Fatalf("expected valid response, got %v", err)
+	}
+	if resp.Stratum != 1 || resp.ReferenceString() != ".LOCL." || resp.Version != 3 {
+		t.Errorf("unexpected response: stratum %d, ref %s, version %d",
+			resp.Stratum, resp.ReferenceString(), resp.Version)
+	}
+	if resp.ClockOffset < -100*time.Millisecond || resp.ClockOffset > 100*time.Millisecond {
+		t.Errorf("expected offset near zero, got %s", resp.ClockOffset)
+	}
+}
+
+func TestServerUpstream(t *testing.T) {
+	upstream := startFakeServer(t, 2*time.Second)
+	source := NewUpstreamClock(Options{Servers: []string{upstream.String()}, Timeout: time.Second})
+
+	srv, err := NewServer(ServerOptions{Stratum: 3, RefID: "127.0.0.1", Source: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startServer(t, srv)
+	opts := Options{Servers: []string{addr.String()}, Timeout: time.Second}
+
+	// До первой синхронизации сервер сообщает, что часы не синхронизированы
+	if _, err := Query(opts); err == nil {
+		t.Errorf("expected error before sync, got nil")
+	}
+
+	if err := source.Sync(); err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if source.Stratum() != 2 {
+		t.Errorf("expected upstream stratum 2, got %d", source.Stratum())
+	}
+	res, err := Query(opts)
+	if err != nil {
+		t.Fatalf("expected nil, got error: %v", err)
+	}
+	if diff := res.Offset - 2*time.Second; diff < -100*time.Millisecond || diff > 100*time.Millisecond {
+		t.Errorf("expected offset near 2s, got %s", res.Offset)
+	}
+}
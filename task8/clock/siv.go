@@ -0,0 +1,142 @@
+package clock
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// errSIVOpen — ошибка проверки подлинности шифротекста AES-SIV.
+var errSIVOpen = errors.New("aes-siv: message authentication failed")
+
+// aesSIV реализует AEAD_AES_SIV_CMAC_256 (RFC 5297), обязательный для NTS.
+type aesSIV struct {
+	mac cipher.Block // K1, используется в S2V
+	ctr cipher.Block // K2, используется для шифрования в режиме CTR
+}
+
+// newAESSIV создаёт шифр из 32-байтового ключа K1 || K2.
+func newAESSIV(key []byte) (*aesSIV, error) {
+	if len(key) != 32 {
+		return nil, errors.New("aes-siv: key must be 32 bytes")
+	}
+	mac, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(key[16:])
+	if err != nil {
+		return nil, err
+	}
+	return &aesSIV{mac: mac, ctr: ctr}, nil
+}
+
+// Seal шифрует plaintext и возвращает V || C. Компоненты ad (в NTS — сам
+// пакет и nonce) аутентифицируются, но не шифруются.
+func (s *aesSIV) Seal(plaintext []byte, ad ...[]byte) []byte {
+	v := s.s2v(append(ad, plaintext)...)
+	out := make([]byte, aes.BlockSize+len(plaintext))
+	copy(out, v[:])
+	s.xorCTR(out[aes.BlockSize:], plaintext, v)
+	return out
+}
+
+// Open проверяет и расшифровывает результат Seal.
+func (s *aesSIV) Open(ciphertext []byte, ad ...[]byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errSIVOpen
+	}
+	var v [aes.BlockSize]byte
+	copy(v[:], ciphertext)
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+	s.xorCTR(plaintext, ciphertext[aes.BlockSize:], v)
+
+	expected := s.s2v(append(ad, plaintext)...)
+	if subtle.ConstantTimeCompare(expected[:], v[:]) != 1 {
+		return nil, errSIVOpen
+	}
+	return plaintext, nil
+}
+
+// xorCTR шифрует src в режиме CTR с начальным счётчиком, полученным из V.
+func (s *aesSIV) xorCTR(dst, src []byte, v [aes.BlockSize]byte) {
+	v[8] &= 0x7f
+	v[12] &= 0x7f
+	cipher.NewCTR(s.ctr, v[:]).XORKeyStream(dst, src)
+}
+
+// s2v — псевдослучайная функция над вектором строк (RFC 5297, 2.4).
+func (s *aesSIV) s2v(components ...[]byte) [aes.BlockSize]byte {
+	var zero, d [aes.BlockSize]byte
+	if len(components) == 0 {
+		zero[aes.BlockSize-1] = 1
+		return s.cmac(zero[:])
+	}
+
+	d = s.cmac(zero[:])
+	last := len(components) - 1
+	for _, c := range components[:last] {
+		d = dbl(d)
+		m := s.cmac(c)
+		subtle.XORBytes(d[:], d[:], m[:])
+	}
+
+	sn := components[last]
+	var t []byte
+	if len(sn) >= aes.BlockSize {
+		t = append([]byte(nil), sn...)
+		tail := t[len(t)-aes.BlockSize:]
+		subtle.XORBytes(tail, tail, d[:])
+	} else {
+		d = dbl(d)
+		var padded [aes.BlockSize]byte
+		copy(padded[:], sn)
+		padded[len(sn)] = 0x80
+		subtle.XORBytes(padded[:], padded[:], d[:])
+		t = padded[:]
+	}
+	return s.cmac(t)
+}
+
+// cmac вычисляет AES-CMAC (RFC 4493) ключом K1.
+func (s *aesSIV) cmac(msg []byte) [aes.BlockSize]byte {
+	var l [aes.BlockSize]byte
+	s.mac.Encrypt(l[:], l[:])
+	k1 := dbl(l)
+	k2 := dbl(k1)
+
+	var last [aes.BlockSize]byte
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	if n > 0 && len(msg)%aes.BlockSize == 0 {
+		subtle.XORBytes(last[:], msg[(n-1)*aes.BlockSize:], k1[:])
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := msg[(n-1)*aes.BlockSize:]
+		copy(last[:], rest)
+		last[len(rest)] = 0x80
+		subtle.XORBytes(last[:], last[:], k2[:])
+	}
+
+	var x [aes.BlockSize]byte
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x[:], x[:], msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		s.mac.Encrypt(x[:], x[:])
+	}
+	subtle.XORBytes(x[:], x[:], last[:])
+	s.mac.Encrypt(x[:], x[:])
+	return x
+}
+
+// dbl умножает блок на x в GF(2^128).
+func dbl(b [aes.BlockSize]byte) [aes.BlockSize]byte {
+	var out [aes.BlockSize]byte
+	carry := b[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[aes.BlockSize-1] = b[aes.BlockSize-1]<<1 ^ carry*0x87
+	return out
+}
@@ -0,0 +1,43 @@
+package clock
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestAESSIVVector проверяет шифр на тестовом векторе RFC 5297, A.1.
+func TestAESSIVVector(t *testing.T) {
+	key := mustHex(t, "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	ad := mustHex(t, "101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext := mustHex(t, "112233445566778899aabbccddee")
+	want := mustHex(t, "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+	siv, err := newAESSIV(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := siv.Seal(plaintext, ad)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+
+	opened, err := siv.Open(got, ad)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("expected %x, got %x (%v)", plaintext, opened, err)
+	}
+
+	got[len(got)-1] ^= 1
+	if _, err := siv.Open(got, ad); err == nil {
+		t.Errorf("expected error for tampered ciphertext, got nil")
+	}
+}
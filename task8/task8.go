@@ -1,46 +1,240 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"time"
 
-	"github.com/beevik/ntp"
+	"l2/task8/clock"
 )
 
-// Можно добавить больше адресов для лучшей отказоустойчивости
-var ipAddresses = [...]string{
-	"0.beevik-ntp.pool.ntp.org",
-	"1.beevik-ntp.pool.ntp.org",
-	"2.beevik-ntp.pool.ntp.org",
-	"3.beevik-ntp.pool.ntp.org",
-}
+// go run task8.go -servers=time.google.com,time.cloudflare.com -timeout=2s
+// go run task8.go -report
+// go run task8.go -watch=1m -format=json
+// go run task8.go -output=json -tz=Europe/Moscow
+// sudo go run task8.go -set -dry-run
+// go run task8.go serve -listen=:1123 -upstream
+// go run task8.go -nts -servers=time.cloudflare.com
+// go run task8.go -keys=/etc/ntp.keys -keyid=1 -servers=ntp.example.com
 
 func main() {
-	if err := PrintCurrentTime(); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+
+	fs := flag.CommandLine
+	clientOpts := clientFlags(fs)
+	var (
+		report = fs.Bool("report", false, "вывести смещение локальных часов, задержку, stratum, reference ID и leap")
+		watch  = fs.Duration("watch", 0, "опрашивать серверы с указанным интервалом и выводить дрейф смещения")
+		count  = fs.Int("count", 0, "число опросов в режиме -watch (0 — бесконечно)")
+		format = fs.String("format", "table", "формат вывода -watch: table или json")
+		output = fs.String("output", "default", "формат времени: default, rfc3339, unix, unixnano, json или layout")
+		layout = fs.String("layout", "", "layout Go для вывода времени, например \"2006-01-02 15:04:05\" (включает -output=layout)")
+		tz     = fs.String("tz", "", "часовой пояс IANA для вывода, например Europe/Moscow")
+		utc    = fs.Bool("utc", false, "выводить время в UTC")
+		set    = fs.Bool("set", false, "скорректировать системные часы: скачком или плавно по порогу -step (нужен CAP_SYS_TIME)")
+		slew   = fs.Bool("slew", false, "скорректировать системные часы только плавной подстройкой (нужен CAP_SYS_TIME)")
+		step   = fs.Duration("step", clock.DefaultStepThreshold, "смещение, начиная с которого -set переводит часы скачком")
+		panicT = fs.Duration("panic", 0, "с -set/-slew отказаться от коррекции, если смещение больше порога, например 1000s как у ntpd (0 — без ограничения, как ntpdate)")
+		dryRun = fs.Bool("dry-run", false, "с -set/-slew только показать, что будет сделано")
+	)
+	flag.Parse()
+
+	opts, err := clientOpts()
+	if err != nil {
 		log.Fatal(err)
 	}
+	out, err := outputOptions(*output, *layout, *tz, *utc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch {
+	case *set || *slew:
+		adjOpts := clock.AdjustOptions{
+			Mode:           clock.AdjustAuto,
+			StepThreshold:  *step,
+			PanicThreshold: *panicT,
+			DryRun:         *dryRun,
+		}
+		if *slew {
+			adjOpts.Mode = clock.AdjustSlew
+		}
+		res, err := clock.Query(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		adj, err := clock.Adjust(res, adjOpts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s (server %s)\n", adj, res.Server)
+	case *watch > 0:
+		out, err := clock.NewDriftWriter(os.Stdout, *format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		err = clock.Watch(ctx, opts, *watch, *count, out)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case *report:
+		res, err := clock.Query(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := clock.PrintReport(os.Stdout, res); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		if err := clock.PrintCurrentTime(os.Stdout, opts, out); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
 
-// PrintCurrentTime получает текущее время с одного из NTP-серверов и выводит в консоль.
-func PrintCurrentTime() error {
-	currentTime, err := CurrentTime()
+// outputOptions собирает формат вывода времени из флагов.
+func outputOptions(output, layout, tz string, utc bool) (clock.Output, error) {
+	format, err := clock.ParseFormat(output)
 	if err != nil {
-		return err
+		return clock.Output{}, err
+	}
+	if layout != "" {
+		format = clock.FormatLayout
+	}
+	out := clock.Output{Format: format, Layout: layout}
+
+	switch {
+	case utc && tz != "":
+		return clock.Output{}, errors.New("-utc and -tz cannot be combined")
+	case utc:
+		out.Location = time.UTC
+	case tz != "":
+		out.Location, err = time.LoadLocation(tz)
+		if err != nil {
+			return clock.Output{}, err
+		}
 	}
-	fmt.Printf("Current time: %s\n", currentTime)
-	return nil
+	return out, nil
 }
 
-// CurrentTime получает текущее время с одного из NTP-серверов.
-func CurrentTime() (time.Time, error) {
-	var lastErr error
-	for _, url := range ipAddresses {
-		currentTime, err := ntp.Time(url)
-		if err == nil {
-			return currentTime, nil
+// clientFlags регистрирует флаги опроса NTP-серверов и возвращает функцию,
+// собирающую из них clock.Options после разбора аргументов.
+func clientFlags(fs *flag.FlagSet) func() (clock.Options, error) {
+	var (
+		servers    = fs.String("servers", "", "список NTP-серверов через запятую (иначе -config или $NTP_SERVERS)")
+		configPath = fs.String("config", "", "файл в формате ntp.conf со строками server/pool")
+		timeout    = fs.Duration("timeout", 5*time.Second, "таймаут ответа одного сервера")
+		version    = fs.Int("version", 4, "версия протокола NTP")
+		port       = fs.Int("port", 123, "порт NTP для адресов без явного порта")
+		nts        = fs.Bool("nts", false, "использовать Network Time Security (серверы — адреса NTS-KE)")
+		ntsCA      = fs.String("nts-ca", "", "PEM-файл с корневыми сертификатами для NTS-KE")
+		keysPath   = fs.String("keys", "", "файл симметричных ключей в формате ntp.keys")
+		keyID      clock.KeyID
+	)
+	fs.Var(&keyID, "keyid", "идентификатор ключа из -keys (1..65535) для аутентификации запросов")
+	return func() (clock.Options, error) {
+		list, err := clock.ResolveServers(*servers, *configPath)
+		if err != nil {
+			return clock.Options{}, err
+		}
+		opts := clock.Options{
+			Servers: list,
+			Timeout: *timeout,
+			Version: *version,
+			Port:    *port,
+			NTS:     *nts,
+		}
+		if *ntsCA != "" {
+			pem, err := os.ReadFile(*ntsCA)
+			if err != nil {
+				return clock.Options{}, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return clock.Options{}, errors.New("no certificates found in " + *ntsCA)
+			}
+			opts.TLSConfig = &tls.Config{RootCAs: pool}
+		}
+		if keyID != 0 {
+			if *keysPath == "" {
+				return clock.Options{}, errors.New("-keyid requires -keys")
+			}
+			opts.Auth, err = clock.LoadKey(*keysPath, uint16(keyID))
+			if err != nil {
+				return clock.Options{}, err
+			}
+		}
+		return opts, nil
+	}
+}
+
+// serve запускает SNTP-сервер (подкоманда serve).
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	clientOpts := clientFlags(fs)
+	var (
+		listen   = fs.String("listen", ":123", "UDP-адрес для приёма запросов")
+		stratum  = fs.Uint("stratum", 0, "stratum сервера, 1..15 (по умолчанию 1 для локальных часов, upstream+1 для -upstream)")
+		refID    = fs.String("refid", "", "reference ID: до 4 ASCII-символов или IPv4 (по умолчанию LOCL)")
+		upstream = fs.Bool("upstream", false, "отдавать время вышестоящих NTP-серверов вместо локальных часов")
+		refresh  = fs.Duration("refresh", 5*time.Minute, "интервал синхронизации с вышестоящими серверами")
+	)
+	fs.Parse(args)
+	if _, err := clock.ResolveStratum(*stratum, 0); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	srvOpts := clock.ServerOptions{
+		RefID:  *refID,
+		Source: clock.LocalClock{},
+	}
+	var upstreamStratum uint8
+	if *upstream {
+		opts, err := clientOpts()
+		if err != nil {
+			log.Fatal(err)
+		}
+		source := clock.NewUpstreamClock(opts)
+		if err := source.Sync(); err != nil {
+			log.Fatal(err)
 		}
-		lastErr = err
+		go source.Run(ctx, *refresh, func(err error) {
+			log.Printf("upstream sync failed: %v", err)
+		})
+		srvOpts.Source = source
+		upstreamStratum = source.Stratum()
+	}
+	var err error
+	if srvOpts.Stratum, err = clock.ResolveStratum(*stratum, upstreamStratum); err != nil {
+		log.Fatal(err)
+	}
+	if srvOpts.RefID == "" {
+		srvOpts.RefID = "LOCL"
+	}
+
+	srv, err := clock.NewServer(srvOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("serving SNTP on %s (stratum %d, refid %s)", *listen, srvOpts.Stratum, srvOpts.RefID)
+	if err := srv.ListenAndServe(ctx, *listen); err != nil {
+		log.Fatal(err)
 	}
-	return time.Time{}, fmt.Errorf("failed to get current time from all ntp-servers: %w", lastErr)
 }
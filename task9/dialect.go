@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// Dialect — набор расширений синтаксиса упакованной строки.
+// Расширения можно комбинировать, нулевое значение — базовый синтаксис.
+type Dialect uint8
+
+const (
+	// DialectGraphemes повторяет графемный кластер целиком, а не последний
+	// символ: "е́3" — три буквы е с ударением, "👩‍💻2" — два эмодзи.
+	DialectGraphemes Dialect = 1 << iota
+	// DialectCountFirst записывает число повторов перед символом: "4a3b".
+	DialectCountFirst
+	// DialectGroups повторяет группы в скобках: "(ab)3", "((ab)2c)2".
+	// Скобки в тексте экранируются обратным слешем.
+	DialectGroups
+)
+
+var dialectNames = []struct {
+	dialect Dialect
+	name    string
+}{
+	{DialectGraphemes, "graphemes"},
+	{DialectCountFirst, "count-first"},
+	{DialectGroups, "groups"},
+}
+
+// ParseDialect разбирает список расширений через запятую,
+// например "graphemes,groups". Пустая строка и "runes" — базовый синтаксис.
+func ParseDialect(s string) (Dialect, error) {
+	var dialect Dialect
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || field == "runes" {
+			continue
+		}
+		found := false
+		for _, d := range dialectNames {
+			if d.name == field {
+				dialect |= d.dialect
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown dialect %q", field)
+		}
+	}
+	return dialect, nil
+}
+
+func (d Dialect) String() string {
+	var names []string
+	for _, dn := range dialectNames {
+		if d&dn.dialect != 0 {
+			names = append(names, dn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "runes"
+	}
+	return strings.Join(names, ",")
+}
+
+// escapable сообщает, нужно ли экранировать символ в этом диалекте.
+func (d Dialect) escapable(v rune) bool {
+	return isEscapable(v) || d&DialectGroups != 0 && (v == '(' || v == ')')
+}
+
+// special сообщает, имеет ли символ синтаксическое значение внутри строки.
+// Такие символы никогда не продолжают графемный кластер.
+func (d Dialect) special(v rune) bool {
+	return isASCIIDigit(v) || v == '\\' || d&DialectGroups != 0 && (v == '(' || v == ')')
+}
+
+// units разбивает строку на единицы повторения: символы или, в режиме
+// DialectGraphemes, графемные кластеры, разрезанные перед специальными символами.
+func (d Dialect) units(str string) []string {
+	var units []string
+	if d&DialectGraphemes == 0 {
+		for _, v := range str {
+			units = append(units, string(v))
+		}
+		return units
+	}
+
+	state := -1
+	for str != "" {
+		var cluster string
+		cluster, str, _, state = uniseg.FirstGraphemeClusterInString(str, state)
+		start := 0
+		for i, v := range cluster {
+			if i > 0 && d.special(v) {
+				units = append(units, cluster[start:i])
+				start = i
+			}
+		}
+		units = append(units, cluster[start:])
+	}
+	return units
+}
+
+// clusterContinues сообщает, продолжает ли символ v графемный кластер unit.
+func (d Dialect) clusterContinues(unit []byte, v rune) bool {
+	if d.special(v) {
+		return false
+	}
+	candidate := utf8.AppendRune(unit[:len(unit):len(unit)], v)
+	cluster, _, _, _ := uniseg.FirstGraphemeCluster(candidate, -1)
+	return len(cluster) == len(candidate)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reason — код причины ошибки разбора упакованной строки.
+type Reason int
+
+const (
+	ReasonLeadingDigit   Reason = iota + 1 // число повторов без символа перед ним
+	ReasonTrailingEscape                   // Strict: обратный слеш в конце строки
+	ReasonInvalidEscape                    // Strict: экранирован символ, который не цифра и не слеш
+	ReasonCountOverflow                    // число повторов не помещается в int64
+	ReasonZeroCount                        // Strict: число повторов равно нулю
+	ReasonLeadingZero                      // Strict: число повторов с ведущим нулём
+	ReasonNonASCIIDigit                    // неэкранированная не-ASCII цифра
+	ReasonInvalidUTF8                      // некорректная последовательность UTF-8
+	ReasonTrailingCount                    // DialectCountFirst: число повторов без символа после него
+	ReasonUnclosedGroup                    // DialectGroups: незакрытая скобка
+	ReasonUnmatchedGroup                   // DialectGroups: закрывающая скобка без открывающей
+)
+
+var reasonNames = map[Reason]string{
+	ReasonLeadingDigit:   "repeat count without a preceding character",
+	ReasonTrailingEscape: "trailing backslash without an escaped character",
+	ReasonInvalidEscape:  "only digits and backslashes can be escaped",
+	ReasonCountOverflow:  "repeat count is too large",
+	ReasonZeroCount:      "repeat count must be positive",
+	ReasonLeadingZero:    "repeat count has a leading zero",
+	ReasonNonASCIIDigit:  "non-ASCII digit must be escaped",
+	ReasonInvalidUTF8:    "invalid UTF-8",
+	ReasonTrailingCount:  "repeat count without a following character",
+	ReasonUnclosedGroup:  "unclosed group",
+	ReasonUnmatchedGroup: "closing parenthesis without a matching group",
+}
+
+func (r Reason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reason(%d)", int(r))
+}
+
+// UnpackError описывает ошибку в упакованной строке и её положение.
+// errors.Is(err, ErrInvalidString) возвращает true для любой UnpackError.
+type UnpackError struct {
+	Offset     int64  // смещение начала ошибочного фрагмента в байтах
+	RuneOffset int64  // смещение начала ошибочного фрагмента в символах
+	Token      string // ошибочный фрагмент
+	Reason     Reason
+}
+
+func (e *UnpackError) Error() string {
+	return fmt.Sprintf("%s at byte %d (rune %d): %s: %q",
+		ErrInvalidString, e.Offset, e.RuneOffset, e.Reason, e.Token)
+}
+
+// Is позволяет сравнивать UnpackError с ErrInvalidString.
+func (e *UnpackError) Is(target error) bool {
+	return target == ErrInvalidString
+}
+
+// Diagram возвращает строку input и каретку под ошибочным фрагментом.
+func (e *UnpackError) Diagram(input string) string {
+	return diagram([]byte(input), 0, e)
+}
+
+// diagramWidth — сколько символов контекста показывать с каждой стороны ошибки.
+const diagramWidth = 40
+
+// diagram рисует каретку под ошибкой; text — фрагмент входа, начинающийся
+// со смещения base. Показывается только строка с ошибкой, обрезанная до
+// diagramWidth символов с каждой стороны.
+func diagram(text []byte, base int64, e *UnpackError) string {
+	pos := int(e.Offset - base)
+	if pos < 0 || pos > len(text) {
+		return ""
+	}
+
+	before := text[:pos]
+	if i := bytes.LastIndexByte(before, '\n'); i >= 0 {
+		before = before[i+1:]
+	}
+	after := text[pos:]
+	if i := bytes.IndexByte(after, '\n'); i >= 0 {
+		after = after[:i]
+	}
+
+	prefix, suffix := "", ""
+	if utf8.RuneCount(before) > diagramWidth {
+		before = lastRunes(before, diagramWidth)
+		prefix = "…"
+	}
+	if utf8.RuneCount(after) > diagramWidth {
+		after = firstRunes(after, diagramWidth)
+		suffix = "…"
+	}
+
+	width := utf8.RuneCountInString(e.Token)
+	if width == 0 {
+		width = 1
+	}
+	indent := utf8.RuneCountInString(prefix) + utf8.RuneCount(before)
+	return fmt.Sprintf("%s%s%s%s\n%s^%s",
+		prefix, before, after, suffix,
+		strings.Repeat(" ", indent), strings.Repeat("~", width-1))
+}
+
+// firstRunes возвращает первые n символов b.
+func firstRunes(b []byte, n int) []byte {
+	i := 0
+	for ; n > 0 && i < len(b); n-- {
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+	}
+	return b[:i]
+}
+
+// lastRunes возвращает последние n символов b.
+func lastRunes(b []byte, n int) []byte {
+	i := len(b)
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRune(b[:i])
+		i -= size
+	}
+	return b[i:]
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidString — общая ошибка некорректной упакованной строки.
+// Подробности доступны через *UnpackError.
+var ErrInvalidString = errors.New("invalid string")
+
+// UnpackOptions задаёт диалект и ограничивает размер распакованных данных.
+type UnpackOptions struct {
+	Dialect   Dialect // расширения синтаксиса
+	Strict    bool    // отклонять слеш без экранируемого символа, нулевое число повторов и ведущие нули
+	MaxOutput int64   // максимальный размер вывода в байтах, 0 — без ограничения
+	MaxRatio  float64 // максимальное отношение вывода к прочитанному входу, 0 — без ограничения
+}
+
+// OutputLimitError возвращается, если вывод превысил UnpackOptions.MaxOutput.
+type OutputLimitError struct {
+	Limit int64 // допустимый размер вывода
+	Size  int64 // размер, который получился бы после распаковки очередного символа
+}
+
+func (e *OutputLimitError) Error() string {
+	return fmt.Sprintf("output size %d exceeds limit %d bytes", e.Size, e.Limit)
+}
+
+// RatioLimitError возвращается, если коэффициент расширения превысил
+// UnpackOptions.MaxRatio.
+type RatioLimitError struct {
+	Limit  float64 // допустимый коэффициент расширения
+	Input  int64   // прочитано байт входа
+	Output int64   // байт вывода после распаковки очередного символа
+}
+
+func (e *RatioLimitError) Error() string {
+	return fmt.Sprintf("expansion ratio %.1f (%d -> %d bytes) exceeds limit %.1f",
+		float64(e.Output)/float64(e.Input), e.Input, e.Output, e.Limit)
+}
+
+// UnpackStream читает упакованную строку из r и пишет распакованную в w,
+// не накапливая результат в памяти. Ограничения проверяются до записи
+// очередной серии символов, поэтому при ошибке лимита лишние данные не пишутся.
+// В диалекте DialectGroups в памяти хранится содержимое незакрытых групп;
+// оно тоже учитывается в ограничениях.
+func UnpackStream(r io.Reader, w io.Writer, opts UnpackOptions) error {
+	d := &decoder{in: bufio.NewReader(r), out: bufio.NewWriter(w), opts: opts}
+	err := d.run()
+	if ferr := d.out.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// decoder хранит состояние потоковой распаковки.
+type decoder struct {
+	in      *bufio.Reader
+	out     *bufio.Writer
+	opts    UnpackOptions
+	read    int64 // прочитано байт входа
+	runes   int64 // прочитано символов входа
+	written int64 // записано байт вывода
+	pending int64 // байт в буферах незакрытых групп
+
+	// Текущий токен: символ (возможно экранированный) и число повторов
+	tokenOffset int64
+	tokenRune   int64
+	token       []byte
+
+	groups []group // незакрытые группы, последняя — самая вложенная
+}
+
+// group — открытая скобка диалекта DialectGroups.
+type group struct {
+	offset     int64
+	runeOffset int64
+	token      string
+	count      int64 // число повторов перед скобкой для DialectCountFirst
+	buf        []byte
+}
+
+func (d *decoder) run() error {
+	dialect := d.opts.Dialect
+	countFirst := dialect&DialectCountFirst != 0
+	for {
+		d.tokenOffset, d.tokenRune, d.token = d.read, d.runes, d.token[:0]
+		count := int64(1)
+		if countFirst {
+			var err error
+			if count, err = d.readCount(); err != nil {
+				return err
+			}
+		}
+		hasCount := len(d.token) > 0
+
+		start := len(d.token)
+		v, err := d.next()
+		if err == io.EOF {
+			if hasCount {
+				return d.fail(ReasonTrailingCount)
+			}
+			return d.finish()
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case dialect&DialectGroups != 0 && v == '(':
+			d.groups = append(d.groups, group{
+				offset:     d.tokenOffset,
+				runeOffset: d.tokenRune,
+				token:      string(d.token),
+				count:      count,
+			})
+			continue
+		case dialect&DialectGroups != 0 && v == ')':
+			if len(d.groups) == 0 {
+				return d.fail(ReasonUnmatchedGroup)
+			}
+			if hasCount {
+				return d.fail(ReasonTrailingCount)
+			}
+			g := d.groups[len(d.groups)-1]
+			d.groups = d.groups[:len(d.groups)-1]
+			d.pending -= int64(len(g.buf))
+			count = g.count
+			if !countFirst {
+				if count, err = d.readCount(); err != nil {
+					return err
+				}
+			}
+			if err := d.emit(g.buf, count); err != nil {
+				return err
+			}
+			continue
+		case v == '\\':
+			escaped, err := d.escapes()
+			if err != nil {
+				return err
+			}
+			if escaped {
+				start = len(d.token)
+				if _, err := d.next(); err != nil {
+					return err
+				}
+			}
+		case isASCIIDigit(v):
+			d.readDigits()
+			return d.fail(ReasonLeadingDigit)
+		case unicode.IsDigit(v):
+			return d.fail(ReasonNonASCIIDigit)
+		}
+
+		if dialect&DialectGraphemes != 0 {
+			if err := d.extendCluster(start); err != nil {
+				return err
+			}
+		}
+		unit := d.token[start:]
+		if !countFirst {
+			if count, err = d.readCount(); err != nil {
+				return err
+			}
+		}
+		if err := d.emit(unit, count); err != nil {
+			return err
+		}
+	}
+}
+
+// finish проверяет, что все группы закрыты.
+func (d *decoder) finish() error {
+	if len(d.groups) == 0 {
+		return nil
+	}
+	g := d.groups[len(d.groups)-1]
+	d.tokenOffset, d.tokenRune, d.token = g.offset, g.runeOffset, append(d.token[:0], g.token...)
+	return d.fail(ReasonUnclosedGroup)
+}
+
+// extendCluster дочитывает графемный кластер, начинающийся с d.token[start:].
+func (d *decoder) extendCluster(start int) error {
+	for {
+		v, size, err := d.in.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if v == utf8.RuneError && size == 1 || !d.opts.Dialect.clusterContinues(d.token[start:], v) {
+			d.in.UnreadRune()
+			return nil
+		}
+		d.token = utf8.AppendRune(d.token, v)
+		d.read += int64(size)
+		d.runes++
+	}
+}
+
+// escapes сообщает, экранирует ли только что прочитанный обратный слеш
+// следующий символ. Слеш в конце строки или перед символом, который нельзя
+// экранировать, остаётся обычным символом, а при Strict — это ошибка.
+func (d *decoder) escapes() (bool, error) {
+	v, _, err := d.in.ReadRune()
+	if err == io.EOF {
+		if d.opts.Strict {
+			return false, d.fail(ReasonTrailingEscape)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	d.in.UnreadRune()
+	if d.opts.Dialect.escapable(v) {
+		return true, nil
+	}
+	if d.opts.Strict {
+		if _, err := d.next(); err != nil {
+			return false, err
+		}
+		return false, d.fail(ReasonInvalidEscape)
+	}
+	return false, nil
+}
+
+// next читает очередной символ текущего токена.
+func (d *decoder) next() (rune, error) {
+	v, size, err := d.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if v == utf8.RuneError && size == 1 {
+		d.in.UnreadRune()
+		b, _ := d.in.ReadByte()
+		d.token = append(d.token, b)
+		d.read++
+		d.runes++
+		return 0, d.fail(ReasonInvalidUTF8)
+	}
+	d.token = utf8.AppendRune(d.token, v)
+	d.read += int64(size)
+	d.runes++
+	return v, nil
+}
+
+// readDigits читает подряд идущие ASCII-цифры и возвращает их.
+func (d *decoder) readDigits() ([]byte, error) {
+	start := len(d.token)
+	for {
+		v, size, err := d.in.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !isASCIIDigit(v) {
+			d.in.UnreadRune()
+			break
+		}
+		d.token = append(d.token, byte(v))
+		d.read += int64(size)
+		d.runes++
+	}
+	return d.token[start:], nil
+}
+
+// readCount читает необязательное число повторов после символа. Нулевое
+// число считается отсутствующим, а ведущие нули не учитываются; при Strict
+// и то и другое — ошибка.
+func (d *decoder) readCount() (int64, error) {
+	digits, err := d.readDigits()
+	if err != nil {
+		return 0, err
+	}
+	if len(digits) == 0 {
+		return 1, nil
+	}
+
+	var count int64
+	for _, c := range digits {
+		digit := int64(c - '0')
+		if count > (math.MaxInt64-digit)/10 {
+			return 0, d.fail(ReasonCountOverflow)
+		}
+		count = count*10 + digit
+	}
+	switch {
+	case !d.opts.Strict && count == 0:
+		return 1, nil
+	case count == 0:
+		return 0, d.fail(ReasonZeroCount)
+	case d.opts.Strict && digits[0] == '0':
+		return 0, d.fail(ReasonLeadingZero)
+	}
+	return count, nil
+}
+
+// fail возвращает ошибку для текущего токена.
+func (d *decoder) fail(reason Reason) error {
+	return &UnpackError{
+		Offset:     d.tokenOffset,
+		RuneOffset: d.tokenRune,
+		Token:      string(d.token),
+		Reason:     reason,
+	}
+}
+
+// emit проверяет ограничения и пишет unit count раз: в вывод или,
+// внутри группы, в её буфер.
+func (d *decoder) emit(unit []byte, count int64) error {
+	n := int64(len(unit))
+	if n == 0 {
+		return nil
+	}
+	if count > (math.MaxInt64-d.written-d.pending)/n {
+		return d.fail(ReasonCountOverflow)
+	}
+	size := d.written + d.pending + count*n
+
+	if d.opts.MaxOutput > 0 && size > d.opts.MaxOutput {
+		return &OutputLimitError{Limit: d.opts.MaxOutput, Size: size}
+	}
+	if d.opts.MaxRatio > 0 && float64(size) > d.opts.MaxRatio*float64(d.read) {
+		return &RatioLimitError{Limit: d.opts.MaxRatio, Input: d.read, Output: size}
+	}
+
+	if len(d.groups) > 0 {
+		g := &d.groups[len(d.groups)-1]
+		for i := int64(0); i < count; i++ {
+			g.buf = append(g.buf, unit...)
+		}
+		d.pending += count * n
+		return nil
+	}
+	for i := int64(0); i < count; i++ {
+		if _, err := d.out.Write(unit); err != nil {
+			return err
+		}
+	}
+	d.written += count * n
+	return nil
+}
+
+// isASCIIDigit сообщает, является ли символ цифрой 0-9.
+func isASCIIDigit(v rune) bool {
+	return v >= '0' && v <= '9'
+}
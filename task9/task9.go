@@ -2,67 +2,190 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
+// echo 'a4bc2d5e' | go run . -max-output=1M
+// echo 'aaaabccddddde' | go run . -pack
+// printf 'ab\\c3' | go run . -strict  # ошибка с указанием места
+// echo '2(a3b)' | go run . -dialect=count-first,groups
+
 func main() {
-	a := "a4bc2d5e"
-	b, err := Unpacking(a)
+	var (
+		pack        = flag.Bool("pack", false, "упаковать вход вместо распаковки")
+		maxOutput   = flag.String("max-output", "", "максимальный размер вывода, например 64K или 1G")
+		maxRatio    = flag.Float64("max-ratio", 0, "максимальное отношение размера вывода к входу (0 — без ограничения)")
+		dialectName = flag.String("dialect", "", "расширения синтаксиса через запятую: graphemes, count-first, groups")
+		strict      = flag.Bool("strict", false, "отклонять слеш без экранируемого символа, нулевое число повторов и ведущие нули")
+	)
+	flag.Parse()
+
+	dialect, err := ParseDialect(*dialectName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: неверный -dialect: %v\n", err)
+		os.Exit(2)
+	}
+
+	limit, err := parseSize(*maxOutput)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintf(os.Stderr, "Ошибка: неверный -max-output: %v\n", err)
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка открытия файла: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	if *pack {
+		data, err := io.ReadAll(in)
+		if err == nil {
+			_, err = io.WriteString(os.Stdout, PackingDialect(string(data), dialect))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
-	fmt.Println(b) // aaaabccddddde
-}
 
-func Unpacking(str string) (string, error) {
-	res := make([]rune, 0, len(str))
-	var last rune
-	count := 0
-	for i, v := range str {
-		if unicode.IsDigit(v) {
-			if i == 0 {
-				return "", errors.New("invalid string")
-			} else if last == '\\' {
-				last = v
-			} else {
-				count = count*10 + int(v-'0')
-			}
-		} else if v == '\\' {
-			if last != 0 {
-				if count == 0 {
-					res = append(res, last)
-				} else {
-					for j := 0; j < count; j++ {
-						res = append(res, last)
-					}
-					count = 0
-				}
+	opts := UnpackOptions{Dialect: dialect, Strict: *strict, MaxOutput: limit, MaxRatio: *maxRatio}
+	tail := &tailReader{r: in}
+	if err := UnpackStream(tail, os.Stdout, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка распаковки: %v\n", err)
+		var unpackErr *UnpackError
+		if errors.As(err, &unpackErr) {
+			if d := diagram(tail.buf, tail.start, unpackErr); d != "" {
+				fmt.Fprintln(os.Stderr, d)
 			}
-			last = '\\'
-		} else {
-			if last != 0 {
-				if count == 0 {
-					res = append(res, last)
-				} else {
-					for j := 0; j < count; j++ {
-						res = append(res, last)
-					}
-					count = 0
-				}
-			}
-			last = v
 		}
+		os.Exit(1)
 	}
-	if last != 0 {
-		if count != 0 {
-			for j := 0; j < count; j++ {
-				res = append(res, last)
-			}
-		} else {
-			res = append(res, last)
+}
+
+// tailSize — сколько последних байт входа хранит tailReader.
+const tailSize = 64 << 10
+
+// tailReader запоминает последние прочитанные байты, чтобы при ошибке
+// показать место во входе, не держа в памяти весь поток.
+type tailReader struct {
+	r     io.Reader
+	buf   []byte
+	start int64 // смещение buf[0] во входе
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.buf = append(t.buf, p[:n]...)
+	if extra := len(t.buf) - tailSize; extra > 0 {
+		t.buf = append(t.buf[:0], t.buf[extra:]...)
+		t.start += int64(extra)
+	}
+	return n, err
+}
+
+// parseSize разбирает размер в байтах с необязательным суффиксом K, M или G.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'K', 'k':
+		multiplier = 1 << 10
+	case 'M', 'm':
+		multiplier = 1 << 20
+	case 'G', 'g':
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size out of range: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// Unpacking распаковывает строку вида "a4bc2d5e" в "aaaabccddddde".
+// Обратный слеш экранирует следующую за ним цифру или обратный слеш.
+// Ошибки разбора возвращаются как *UnpackError с положением во входе.
+func Unpacking(str string) (string, error) {
+	return UnpackingDialect(str, 0)
+}
+
+// UnpackingDialect распаковывает строку с расширениями синтаксиса dialect.
+func UnpackingDialect(str string, dialect Dialect) (string, error) {
+	var b strings.Builder
+	b.Grow(len(str))
+	if err := UnpackStream(strings.NewReader(str), &b, UnpackOptions{Dialect: dialect}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Packing кодирует строку в кратчайшую запись, которую понимает Unpacking:
+// серии одинаковых символов сворачиваются в символ с числом повторов,
+// цифры и обратные слеши экранируются. Для любой корректной UTF-8 строки
+// Unpacking(Packing(s)) == s.
+func Packing(str string) string {
+	return PackingDialect(str, 0)
+}
+
+// PackingDialect кодирует строку в кратчайшую запись диалекта dialect.
+// Группы не ищутся: для DialectGroups только экранируются скобки.
+// Для любой корректной UTF-8 строки UnpackingDialect(PackingDialect(s, d), d) == s.
+func PackingDialect(str string, dialect Dialect) string {
+	units := dialect.units(str)
+	var b strings.Builder
+	b.Grow(len(str))
+	for i := 0; i < len(units); {
+		unit := units[i]
+		j := i + 1
+		for j < len(units) && units[j] == unit {
+			j++
+		}
+		n := j - i
+		i = j
+
+		token := unit
+		if v, _ := utf8.DecodeRuneInString(unit); dialect.escapable(v) {
+			token = `\` + token
+		}
+		count := strconv.Itoa(n)
+		switch {
+		case n == 1 || len(token)+len(count) > n*len(token):
+			b.WriteString(strings.Repeat(token, n))
+		case dialect&DialectCountFirst != 0:
+			b.WriteString(count)
+			b.WriteString(token)
+		default:
+			b.WriteString(token)
+			b.WriteString(count)
 		}
 	}
-	return string(res), nil
+	return b.String()
+}
+
+// isEscapable сообщает, нужно ли экранировать символ обратным слешем.
+func isEscapable(v rune) bool {
+	return unicode.IsDigit(v) || v == '\\'
 }
@@ -1,6 +1,15 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
 
 func TestUnpacking(t *testing.T) {
 	tests := []struct {
@@ -17,6 +26,12 @@ func TestUnpacking(t *testing.T) {
 		{name: "slash with digit", input: "qwe\\45", want: "qwe44444", wantErr: false},
 		{name: "big digit 1", input: "a10b", want: "aaaaaaaaaab", wantErr: false},
 		{name: "big digit 2", input: "a10b11", want: "aaaaaaaaaabbbbbbbbbbb", wantErr: false},
+		{name: "escaped slash", input: "a\\\\3b", want: "a\\\\\\b", wantErr: false},
+		{name: "escaped slash and digit", input: "\\\\\\4", want: "\\4", wantErr: false},
+		{name: "lone slash", input: "a\\b", want: "a\\b", wantErr: false},
+		{name: "trailing slash", input: "abc\\", want: "abc\\", wantErr: false},
+		{name: "zero count", input: "a0b", want: "ab", wantErr: false},
+		{name: "leading zero", input: "a05", want: "aaaaa", wantErr: false},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -36,3 +51,322 @@ func TestUnpacking(t *testing.T) {
 		})
 	}
 }
+
+func TestPacking(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "base", input: "aaaabccddddde", want: "a4bc2d5e"},
+		{name: "no repeats", input: "abcd", want: "abcd"},
+		{name: "empty string", input: "", want: ""},
+		{name: "digits", input: "qwe45", want: "qwe\\4\\5"},
+		{name: "repeated digit", input: "qwe44444", want: "qwe\\45"},
+		{name: "slashes", input: "a\\\\\\b", want: "a\\\\3b"},
+		{name: "double slash", input: "\\\\", want: "\\\\2"},
+		{name: "big count", input: strings.Repeat("x", 12) + "y", want: "x12y"},
+		{name: "cyrillic", input: "ёёё", want: "ё3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Packing(test.input); got != test.want {
+				t.Errorf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+// packingAlphabet — символы, на которых кодирование ошибается чаще всего
+var packingAlphabet = []rune("ab\\019ё٣ ")
+
+// packedString генерирует строки из packingAlphabet с длинными сериями
+type packedString string
+
+func (packedString) Generate(r *rand.Rand, size int) reflect.Value {
+	var b strings.Builder
+	for i := r.Intn(size + 1); i > 0; i-- {
+		v := packingAlphabet[r.Intn(len(packingAlphabet))]
+		b.WriteString(strings.Repeat(string(v), 1+r.Intn(12)))
+	}
+	return reflect.ValueOf(packedString(b.String()))
+}
+
+func TestPackingRoundTrip(t *testing.T) {
+	roundTrip := func(s string) bool {
+		packed := Packing(s)
+		got, err := Unpacking(packed)
+		if err != nil || got != s {
+			t.Logf("input %q, packed %q, unpacked %q, err %v", s, packed, got, err)
+			return false
+		}
+		return true
+	}
+	cfg := &quick.Config{MaxCount: 2000}
+	if err := quick.Check(roundTrip, cfg); err != nil {
+		t.Error(err)
+	}
+	if err := quick.Check(func(s packedString) bool { return roundTrip(string(s)) }, cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnpackStreamLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		opts      UnpackOptions
+		want      string
+		wantLimit error
+	}{
+		{name: "no limits", input: "a4bc2d5e", want: "aaaabccddddde"},
+		{name: "within output limit", input: "a4bc2d5e", opts: UnpackOptions{MaxOutput: 13}, want: "aaaabccddddde"},
+		{name: "output limit", input: "ab999999999", opts: UnpackOptions{MaxOutput: 1 << 20}, want: "a", wantLimit: &OutputLimitError{}},
+		{name: "output limit multibyte", input: "ё3", opts: UnpackOptions{MaxOutput: 5}, want: "", wantLimit: &OutputLimitError{}},
+		{name: "ratio limit", input: "ab100", opts: UnpackOptions{MaxRatio: 10}, want: "a", wantLimit: &RatioLimitError{}},
+		{name: "within ratio limit", input: "a4b", opts: UnpackOptions{MaxRatio: 2}, want: "aaaab"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := UnpackStream(strings.NewReader(test.input), &out, test.opts)
+			switch want := test.wantLimit.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("expected nil, got error: %v", err)
+				}
+			case *OutputLimitError:
+				if !errors.As(err, &want) || want.Limit != test.opts.MaxOutput {
+					t.Fatalf("expected OutputLimitError, got %v", err)
+				}
+			case *RatioLimitError:
+				if !errors.As(err, &want) || want.Limit != test.opts.MaxRatio {
+					t.Fatalf("expected RatioLimitError, got %v", err)
+				}
+			}
+			if out.String() != test.want {
+				t.Errorf("expected %q, got %q", test.want, out.String())
+			}
+		})
+	}
+}
+
+func TestUnpackStreamOverflow(t *testing.T) {
+	err := UnpackStream(strings.NewReader("a99999999999999999999"), io.Discard, UnpackOptions{})
+	var unpackErr *UnpackError
+	if !errors.As(err, &unpackErr) || unpackErr.Reason != ReasonCountOverflow {
+		t.Errorf("expected ReasonCountOverflow, got %v", err)
+	}
+}
+
+func TestUnpackError(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		offset     int64
+		runeOffset int64
+		token      string
+		reason     Reason
+		strict     bool
+	}{
+		{name: "leading digit", input: "45", offset: 0, runeOffset: 0, token: "45", reason: ReasonLeadingDigit},
+		{name: "trailing escape", input: "ёa\\", offset: 3, runeOffset: 2, token: "\\", reason: ReasonTrailingEscape, strict: true},
+		{name: "invalid escape", input: "ab\\c3", offset: 2, runeOffset: 2, token: "\\c", reason: ReasonInvalidEscape, strict: true},
+		{name: "count overflow", input: "ёёb99999999999999999999", offset: 4, runeOffset: 2, token: "b99999999999999999999", reason: ReasonCountOverflow},
+		{name: "zero count", input: "ab0", offset: 1, runeOffset: 1, token: "b0", reason: ReasonZeroCount, strict: true},
+		{name: "leading zero", input: "ab05", offset: 1, runeOffset: 1, token: "b05", reason: ReasonLeadingZero, strict: true},
+		{name: "non-ASCII digit", input: "a٣", offset: 1, runeOffset: 1, token: "٣", reason: ReasonNonASCIIDigit},
+		{name: "invalid UTF-8", input: "ab\xff", offset: 2, runeOffset: 2, token: "\xff", reason: ReasonInvalidUTF8},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := UnpackStream(strings.NewReader(test.input), io.Discard, UnpackOptions{Strict: test.strict})
+			if !errors.Is(err, ErrInvalidString) {
+				t.Fatalf("expected ErrInvalidString, got %v", err)
+			}
+			var unpackErr *UnpackError
+			if !errors.As(err, &unpackErr) {
+				t.Fatalf("expected UnpackError, got %T", err)
+			}
+			want := UnpackError{Offset: test.offset, RuneOffset: test.runeOffset, Token: test.token, Reason: test.reason}
+			if *unpackErr != want {
+				t.Errorf("expected %+v, got %+v", want, *unpackErr)
+			}
+		})
+	}
+}
+
+func TestUnpackStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		input   string
+		want    string
+		wantErr Reason
+	}{
+		{name: "escaped digit", input: "a\\4\\\\2", want: "a4\\\\"},
+		{name: "count", input: "a10b", want: "aaaaaaaaaab"},
+		{name: "lone slash", input: "a\\b", wantErr: ReasonInvalidEscape},
+		{name: "trailing slash", input: "abc\\", wantErr: ReasonTrailingEscape},
+		{name: "zero count", input: "a0b", wantErr: ReasonZeroCount},
+		{name: "leading zero", input: "a05", wantErr: ReasonLeadingZero},
+		{name: "parenthesis without groups", input: "\\(", wantErr: ReasonInvalidEscape},
+		{name: "parenthesis with groups", dialect: DialectGroups, input: "\\(2", want: "(("},
+		{name: "count first zero", dialect: DialectCountFirst, input: "0a", wantErr: ReasonZeroCount},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+			err := UnpackStream(strings.NewReader(test.input), &out, UnpackOptions{Dialect: test.dialect, Strict: true})
+			if test.wantErr != 0 {
+				var unpackErr *UnpackError
+				if !errors.As(err, &unpackErr) || unpackErr.Reason != test.wantErr {
+					t.Fatalf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil, got error: %v", err)
+			}
+			if out.String() != test.want {
+				t.Errorf("expected %q, got %q", test.want, out.String())
+			}
+		})
+	}
+}
+
+func TestUnpackErrorDiagram(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "invalid escape", input: "ёa\\b", want: "ёa\\b\n  ^~"},
+		{name: "second line", input: "a2\nbc00d", want: "bc00d\n ^~~"},
+		{
+			name:  "long line",
+			input: strings.Repeat("x", 50) + "0" + strings.Repeat("y", 50),
+			want:  "…" + strings.Repeat("x", 41) + "0" + strings.Repeat("y", 38) + "…\n" + strings.Repeat(" ", 41) + "^~",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := UnpackStream(strings.NewReader(test.input), io.Discard, UnpackOptions{Strict: true})
+			var unpackErr *UnpackError
+			if !errors.As(err, &unpackErr) {
+				t.Fatalf("expected UnpackError, got %v", err)
+			}
+			if got := unpackErr.Diagram(test.input); got != test.want {
+				t.Errorf("expected\n%s\ngot\n%s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnpackingDialect(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		input   string
+		want    string
+		wantErr Reason
+	}{
+		{name: "runes repeat last code point", input: "е\u03013", want: "е\u0301\u0301\u0301"},
+		{name: "combining sequence", dialect: DialectGraphemes, input: "е\u03013", want: "е\u0301е\u0301е\u0301"},
+		{name: "ZWJ sequence", dialect: DialectGraphemes, input: "👩\u200d💻2x", want: "👩\u200d💻👩\u200d💻x"},
+		{name: "flag", dialect: DialectGraphemes, input: "🇷🇺🇺🇸2", want: "🇷🇺🇺🇸🇺🇸"},
+		{name: "escaped keycap", dialect: DialectGraphemes, input: "\\1\ufe0f\u20e32", want: "1\ufe0f\u20e31\ufe0f\u20e3"},
+		{name: "count first", dialect: DialectCountFirst, input: "4a3b", want: "aaaabbb"},
+		{name: "count first escaped digit", dialect: DialectCountFirst, input: "3\\4c", want: "444c"},
+		{name: "count first trailing count", dialect: DialectCountFirst, input: "ab3", wantErr: ReasonTrailingCount},
+		{name: "group", dialect: DialectGroups, input: "(ab)3", want: "ababab"},
+		{name: "nested groups", dialect: DialectGroups, input: "((ab)2c)2d", want: "ababcababcd"},
+		{name: "group without count", dialect: DialectGroups, input: "(a2)b", want: "aab"},
+		{name: "escaped parentheses", dialect: DialectGroups, input: "\\(a\\)2", want: "(a))"},
+		{name: "unclosed group", dialect: DialectGroups, input: "(a(b)2", wantErr: ReasonUnclosedGroup},
+		{name: "unmatched parenthesis", dialect: DialectGroups, input: "ab)2", wantErr: ReasonUnmatchedGroup},
+		{name: "slash before parenthesis without groups", input: "\\(", want: "\\("},
+		{name: "count first groups", dialect: DialectCountFirst | DialectGroups, input: "2(a3b)c", want: "abbbabbbc"},
+		{name: "all dialects", dialect: DialectGraphemes | DialectCountFirst | DialectGroups, input: "2(3е\u0301x)", want: "е\u0301е\u0301е\u0301xе\u0301е\u0301е\u0301x"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := UnpackingDialect(test.input, test.dialect)
+			if test.wantErr != 0 {
+				var unpackErr *UnpackError
+				if !errors.As(err, &unpackErr) || unpackErr.Reason != test.wantErr {
+					t.Fatalf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil, got error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnpackStreamGroupLimit(t *testing.T) {
+	opts := UnpackOptions{Dialect: DialectGroups, MaxOutput: 1 << 20}
+	err := UnpackStream(strings.NewReader("(((a999)999)999)"), io.Discard, opts)
+	var limitErr *OutputLimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("expected OutputLimitError, got %v", err)
+	}
+}
+
+// dialectAlphabet — единицы, на которых диалекты ошибаются чаще всего
+var dialectAlphabet = []string{"a", "\\", "1", "(", ")", "٣", "е\u0301", "👩\u200d💻", "🇷🇺", "1\ufe0f\u20e3", "\u0600"}
+
+// dialectString генерирует строки из dialectAlphabet с длинными сериями
+type dialectString string
+
+func (dialectString) Generate(r *rand.Rand, size int) reflect.Value {
+	var b strings.Builder
+	for i := r.Intn(size + 1); i > 0; i-- {
+		unit := dialectAlphabet[r.Intn(len(dialectAlphabet))]
+		b.WriteString(strings.Repeat(unit, 1+r.Intn(12)))
+	}
+	return reflect.ValueOf(dialectString(b.String()))
+}
+
+func TestPackingDialectRoundTrip(t *testing.T) {
+	for dialect := Dialect(0); dialect <= DialectGraphemes|DialectCountFirst|DialectGroups; dialect++ {
+		t.Run(dialect.String(), func(t *testing.T) {
+			roundTrip := func(s dialectString) bool {
+				packed := PackingDialect(string(s), dialect)
+				got, err := UnpackingDialect(packed, dialect)
+				if err != nil || got != string(s) {
+					t.Logf("input %q, packed %q, unpacked %q, err %v", s, packed, got, err)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPackingDialect(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		input   string
+		want    string
+	}{
+		{dialect: DialectGraphemes, input: "е\u0301е\u0301е\u0301", want: "е\u03013"},
+		{dialect: DialectCountFirst, input: "aaaabbbc", want: "4a3bc"},
+		{dialect: DialectGroups, input: "(aa)", want: "\\(a2\\)"},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect.String(), func(t *testing.T) {
+			if got := PackingDialect(test.input, test.dialect); got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}